@@ -0,0 +1,12 @@
+// Package ascii names the control characters that the keyboard,
+// the screen and the frontends pass around, without pulling in
+// SDL as the keyboard package does
+package ascii
+
+// Control characters
+const (
+	Backspace = 8
+	Enter     = 13
+	Escape    = 27
+	Delete    = 127
+)
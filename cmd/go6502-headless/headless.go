@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hculpan/go6502/headless"
+	"github.com/hculpan/go6502/paste"
+	"github.com/hculpan/go6502/runner"
+)
+
+// How often the headless emulator checks whether the CPU has stopped
+const headlessPollInterval = 10 * time.Millisecond
+
+// runHeadlessInput runs headless with the keyboard input from
+// stdin, or from the file if there is one
+func runHeadlessInput(cfg *runner.Config, input string, timeout time.Duration) error {
+	if input == "" {
+		return runHeadless(cfg, os.Stdin, os.Stdout, timeout)
+	}
+
+	file, err := os.Open(input)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return runHeadless(cfg, file, os.Stdout, timeout)
+}
+
+// runHeadless runs the ROM without a window, with the screen going
+// to out and the keyboard coming from in, after the -type-file if
+// there is one.
+// It returns when interrupted, when the timeout expires or when
+// the CPU stops.
+func runHeadless(cfg *runner.Config, in io.Reader, out io.Writer, timeout time.Duration) error {
+	scr := headless.NewScreen(out)
+	em, _, err := runner.NewEmulator(scr, cfg)
+	if err != nil {
+		return err
+	}
+
+	em.StartEmulator()
+	defer em.Close()
+	typist := paste.NewTypist(em, cfg.TypeDelay, cfg.TypeLineDelay)
+	typist.Type(io.MultiReader(bytes.NewReader(cfg.TypeText), in))
+	defer typist.Stop()
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		expired = time.After(timeout)
+	}
+
+	ticker := time.NewTicker(headlessPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-interrupted:
+			return nil
+		case <-expired:
+			return nil
+		case <-ticker.C:
+			if err := runner.CheckStopped(em); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hculpan/go6502/runner"
+)
+
+// TestHeadless boots images headless, typing the input, and checks
+// what they print
+func TestHeadless(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		input  string
+		output string // What the output starts with
+	}{
+		// The built-in rom echoes the keys
+		{"rom", nil, "PRINT 2+3\r", "PRINT 2+3\n"},
+		{"rom image", []string{"../../resources/rom.bin"}, "10 END\n", "10 END\n"},
+		{"txt program", []string{"-load", "../../asm/hello_world.txt"}, "", "Hello, world!\n"},
+	}
+
+	for _, test := range tests {
+		fs := flag.NewFlagSet(test.name, flag.ContinueOnError)
+		flags := runner.AddFlags(fs)
+		if err := fs.Parse(test.args); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := flags.Config(fs.Args())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if err := runHeadless(cfg, strings.NewReader(test.input), &out, 500*time.Millisecond); err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !strings.HasPrefix(out.String(), test.output) {
+			t.Errorf("%s: printed %q, expected %q", test.name, out.String(), test.output)
+		}
+	}
+}
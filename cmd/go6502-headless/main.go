@@ -0,0 +1,43 @@
+// Command go6502-headless runs the emulator without a window,
+// with the screen going to stdout and the keyboard coming from
+// stdin, or with -tty as a full screen in the terminal.  It
+// doesn't use SDL or GTK, so it runs over SSH and in CI without
+// a display.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hculpan/go6502/runner"
+)
+
+func main() {
+	inputFlag := flag.String("input", "", "File to read keyboard input from, defaults to stdin")
+	timeoutFlag := flag.Duration("timeout", 0, "Stop after this long, e.g. 30s, 0 runs until interrupted")
+	ttyFlag := flag.Bool("tty", false, "Show the screen in the terminal, and type into it, rather than using stdin and stdout")
+	flags := runner.AddFlags(flag.CommandLine)
+	flag.Usage = runner.Usage(flag.CommandLine, os.Args[0])
+	flag.Parse()
+
+	cfg, err := flags.Config(flag.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if *ttyFlag {
+		if *inputFlag != "" || *timeoutFlag != 0 {
+			fmt.Fprintln(os.Stderr, "-input and -timeout can't be used with -tty")
+			os.Exit(2)
+		}
+		err = runTerminal(cfg)
+	} else {
+		err = runHeadlessInput(cfg, *inputFlag, *timeoutFlag)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
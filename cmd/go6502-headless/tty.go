@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hculpan/go6502/paste"
+	"github.com/hculpan/go6502/runner"
+	"github.com/hculpan/go6502/terminal"
+	"github.com/hculpan/go6502/termios"
+)
+
+// How often the terminal is redrawn
+const frameInterval = 16 * time.Millisecond
+
+// runTerminal runs the ROM in the host terminal rather than a
+// window, until Ctrl-] is pressed or the CPU stops
+func runTerminal(cfg *runner.Config) error {
+	fd := int(os.Stdin.Fd())
+	state, err := termios.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("Unable to put the terminal in raw mode: %s", err)
+	}
+	defer termios.Restore(fd, state)
+
+	scr := terminal.NewScreen(os.Stdout, runner.TextCols, runner.TextRows, " go6502    Ctrl-]: Exit")
+	if err := scr.Show(); err != nil {
+		return err
+	}
+	defer scr.CleanUp()
+
+	em, _, err := runner.NewEmulator(scr, cfg)
+	if err != nil {
+		return err
+	}
+
+	em.StartEmulator()
+	defer em.Close()
+	typist := paste.NewTypist(em, cfg.TypeDelay, cfg.TypeLineDelay)
+	typist.Type(bytes.NewReader(cfg.TypeText))
+	defer typist.Stop()
+
+	keys := terminal.NewKeyboard(os.Stdin).Keys()
+	ticker := time.NewTicker(frameInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case r, ok := <-keys:
+			if !ok || r == terminal.QuitKey {
+				return nil
+			}
+			em.SetKeyWaiting(r)
+		case <-ticker.C:
+			if err := runner.CheckStopped(em); err != nil {
+				return err
+			}
+			if err := scr.DrawScreen(); err != nil {
+				return err
+			}
+		}
+	}
+}
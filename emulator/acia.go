@@ -0,0 +1,171 @@
+package emulator
+
+// ACIA registers, offsets from the base address
+const (
+	aciaData    = 0x0
+	aciaStatus  = 0x1
+	aciaCommand = 0x2
+	aciaControl = 0x3
+)
+
+// ACIA status register bits
+const (
+	aciaParityError  byte = 0x01
+	aciaFramingError byte = 0x02
+	aciaOverrun      byte = 0x04
+	aciaRDRF         byte = 0x08 // Receive data register full
+	aciaTDRE         byte = 0x10 // Transmit data register empty
+	aciaDCD          byte = 0x20 // Data carrier detect, 0 when connected
+	aciaDSR          byte = 0x40 // Data set ready, 0 when ready
+	aciaIRQ          byte = 0x80
+)
+
+// ACIA command register bits
+const (
+	aciaDTR          byte = 0x01 // Data terminal ready, enables the receiver
+	aciaIRD          byte = 0x02 // Receiver interrupt disabled
+	aciaTIC          byte = 0x0C // Transmitter interrupt control
+	aciaTICInterrupt byte = 0x04 // TIC value for the TDRE interrupt
+	aciaEcho         byte = 0x10
+)
+
+// SerialHost is the host end of a serial port, where the bytes
+// the ACIA sends go and the bytes it receives come from
+type SerialHost interface {
+	Receive() <-chan byte
+	Send(b byte)
+	Connected() bool
+	Close() error
+}
+
+// ACIA emulates the MOS 6551 Asynchronous Communications
+// Interface Adapter, connected to a SerialHost.  The bytes are
+// passed on as they are written and read, the baud rate, word
+// length and parity are accepted but make no difference.
+type ACIA struct {
+	host SerialHost
+
+	rx      byte
+	rxFull  bool
+	overrun bool
+
+	command, control byte
+	irq              bool // Latched until the status is read
+}
+
+// NewACIA creates an ACIA in its reset state.  The host can be
+// nil, for a port with nothing connected.
+func NewACIA(host SerialHost) *ACIA {
+	result := &ACIA{host: host}
+	result.Reset()
+	return result
+}
+
+// Reset emulates the RES pin
+func (a *ACIA) Reset() {
+	a.command = aciaIRD
+	a.control = 0
+	a.rxFull = false
+	a.overrun = false
+	a.irq = false
+}
+
+// Close closes the host, e.g. to stop listening for connections
+func (a *ACIA) Close() error {
+	if a.host == nil {
+		return nil
+	}
+	return a.host.Close()
+}
+
+// Size returns the constant 4
+func (a *ACIA) Size() uint16 {
+	return 4
+}
+
+// IRQ returns true while the ACIA is pulling its IRQ line low
+func (a *ACIA) IRQ() bool {
+	return a.irq || a.transmitInterrupt()
+}
+
+// Read reads one of the ACIA's registers
+func (a *ACIA) Read(address uint16) byte {
+	switch address & 0x03 {
+	case aciaData:
+		a.rxFull = false
+		a.overrun = false
+		return a.rx
+	case aciaStatus:
+		result := aciaTDRE
+		if a.rxFull {
+			result |= aciaRDRF
+		}
+		if a.overrun {
+			result |= aciaOverrun
+		}
+		if !a.connected() {
+			result |= aciaDCD
+		}
+		if a.IRQ() {
+			result |= aciaIRQ
+		}
+		a.irq = false
+		return result
+	case aciaCommand:
+		return a.command
+	default: // aciaControl
+		return a.control
+	}
+}
+
+// Write writes to one of the ACIA's registers
+func (a *ACIA) Write(address uint16, data byte) {
+	switch address & 0x03 {
+	case aciaData:
+		if a.host != nil {
+			a.host.Send(data)
+		}
+	case aciaStatus:
+		// Programmed reset, which leaves the control register
+		// and the parity mode alone
+		a.command &= 0xE0
+		a.command |= aciaIRD
+		a.overrun = false
+	case aciaCommand:
+		a.command = data
+	default: // aciaControl
+		a.control = data
+	}
+}
+
+// Tick takes the next byte from the host once the last one has
+// been read, and interrupts if the receiver interrupt is enabled
+func (a *ACIA) Tick(cycles uint8) {
+	if a.host == nil || a.rxFull || a.command&aciaDTR == 0 {
+		return
+	}
+
+	select {
+	case b := <-a.host.Receive():
+		a.rx = b
+		a.rxFull = true
+		if a.command&aciaIRD == 0 {
+			a.irq = true
+		}
+		if a.command&aciaEcho != 0 && a.command&aciaTIC == 0 {
+			a.host.Send(b)
+		}
+	default:
+	}
+}
+
+func (a *ACIA) connected() bool {
+	return a.host != nil && a.host.Connected()
+}
+
+// transmitInterrupt returns true if the transmitter interrupt is
+// on, the transmit register is always empty so it is asserted
+// for as long as it is enabled
+func (a *ACIA) transmitInterrupt() bool {
+	return a.command&aciaDTR != 0 && a.command&aciaTIC == aciaTICInterrupt
+}
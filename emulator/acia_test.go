@@ -0,0 +1,83 @@
+package emulator
+
+import "testing"
+
+// testHost is a serial host with the bytes to receive queued up
+type testHost struct {
+	rx     chan byte
+	sent   []byte
+	closed bool
+}
+
+func newTestHost(rx ...byte) *testHost {
+	result := &testHost{rx: make(chan byte, len(rx))}
+	for _, b := range rx {
+		result.rx <- b
+	}
+	return result
+}
+
+func (h *testHost) Receive() <-chan byte { return h.rx }
+func (h *testHost) Send(b byte)          { h.sent = append(h.sent, b) }
+func (h *testHost) Connected() bool      { return true }
+func (h *testHost) Close() error         { h.closed = true; return nil }
+
+func TestACIAReceive(t *testing.T) {
+	a := NewACIA(newTestHost('A'))
+	a.Write(aciaCommand, aciaDTR)
+	a.Tick(1)
+
+	if !a.IRQ() {
+		t.Error("No IRQ with the receiver interrupt enabled")
+	}
+	if status := a.Read(aciaStatus); status&(aciaRDRF|aciaIRQ) != aciaRDRF|aciaIRQ {
+		t.Errorf("Status is $%02X", status)
+	}
+	if a.IRQ() {
+		t.Error("Reading the status didn't clear the IRQ")
+	}
+	if b := a.Read(aciaData); b != 'A' {
+		t.Errorf("Received $%02X", b)
+	}
+	if status := a.Read(aciaStatus); status&aciaRDRF != 0 {
+		t.Errorf("Status is $%02X after reading the data", status)
+	}
+}
+
+// TestEmulatorResetsACIA checks that a reset clears the registers
+// and a receive interrupt that hasn't been acknowledged
+func TestEmulatorResetsACIA(t *testing.T) {
+	host := newTestHost('A')
+	a := NewACIA(host)
+	em := &Emulator{CPU: newTestCPU(VariantNMOS), interrupts: NewInterruptController()}
+	em.resetters = append(em.resetters, a)
+	em.closers = append(em.closers, a)
+
+	a.Write(aciaCommand, aciaDTR|aciaEcho)
+	a.Write(aciaControl, 0x1F)
+	a.Tick(1)
+	if !a.IRQ() {
+		t.Fatal("No IRQ with the receiver interrupt enabled")
+	}
+
+	em.Reset()
+	if a.IRQ() {
+		t.Error("IRQ still asserted after reset")
+	}
+	if status := a.Read(aciaStatus); status&(aciaRDRF|aciaIRQ) != 0 {
+		t.Errorf("Status is $%02X after reset", status)
+	}
+	if command := a.Read(aciaCommand); command != aciaIRD {
+		t.Errorf("Command is $%02X after reset", command)
+	}
+	if control := a.Read(aciaControl); control != 0 {
+		t.Errorf("Control is $%02X after reset", control)
+	}
+
+	if err := em.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !host.closed {
+		t.Error("Host not closed")
+	}
+}
@@ -0,0 +1,108 @@
+package emulator
+
+import "fmt"
+
+// AddressBus maps the 16-bit address space of the CPU onto
+// the attached memory components, like ram, rom and IO
+type AddressBus struct {
+	addressables []*addressable
+}
+
+type addressable struct {
+	memory Memory // Actual memory
+	start  uint16 // First address in address space
+	end    uint16 // Last address in address space
+}
+
+func (a *addressable) String() string {
+	return fmt.Sprintf("\t0x%04X-%04X\n", a.start, a.end)
+}
+
+// NewAddressBus creates a new, empty address bus
+func NewAddressBus() (*AddressBus, error) {
+	return &AddressBus{addressables: make([]*addressable, 0)}, nil
+}
+
+// String returns a description of the attached memory
+func (a *AddressBus) String() string {
+	output := "Address Bus:\n"
+
+	for _, addressable := range a.addressables {
+		output += addressable.String()
+	}
+
+	return output
+}
+
+// Attach maps the memory into the address space, starting
+// at the specified offset
+func (a *AddressBus) Attach(memory Memory, offset uint16) {
+	start := offset
+	end := offset + memory.Size() - 1
+	a.addressables = append(a.addressables, &addressable{memory: memory, start: start, end: end})
+}
+
+// Read reads the byte at the specified address.  Addresses
+// with nothing attached read as 0.
+func (a *AddressBus) Read(address uint16) byte {
+	addressable := a.addressableForAddress(address)
+	if addressable == nil {
+		return 0
+	}
+
+	return addressable.memory.Read(address - addressable.start)
+}
+
+// Read16 reads a little-endian 16-bit value from address
+// and address + 1
+func (a *AddressBus) Read16(address uint16) uint16 {
+	lo := uint16(a.Read(address))
+	hi := uint16(a.Read(address + 1))
+
+	return (hi << 8) | lo
+}
+
+// Write writes the byte to the specified address.  Writes
+// to addresses with nothing attached are ignored.
+func (a *AddressBus) Write(address uint16, data byte) {
+	addressable := a.addressableForAddress(address)
+	if addressable == nil {
+		return
+	}
+
+	addressable.memory.Write(address-addressable.start, data)
+}
+
+// LoadByte writes the byte on behalf of the host, e.g. a program
+// loader.  It returns false, without writing anything, if the
+// address isn't RAM or ROM.
+func (a *AddressBus) LoadByte(address uint16, data byte) bool {
+	addressable := a.addressableForAddress(address)
+	if addressable == nil {
+		return false
+	}
+
+	loadable, ok := addressable.memory.(Loadable)
+	if !ok {
+		return false
+	}
+	loadable.LoadByte(address-addressable.start, data)
+	return true
+}
+
+// Write16 writes a little-endian 16-bit value to address
+// and address + 1
+func (a *AddressBus) Write16(address uint16, data uint16) {
+	a.Write(address, byte(data))
+	a.Write(address+1, byte(data>>8))
+}
+
+func (a *AddressBus) addressableForAddress(address uint16) *addressable {
+	for _, addressable := range a.addressables {
+		if addressable.start <= address && addressable.end >= address {
+			return addressable
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package emulator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Common clock speeds, in Hz
+const (
+	ClockUnlimited uint64 = 0
+	Clock1MHz      uint64 = 1000000
+	Clock2MHz      uint64 = 2000000
+)
+
+// If the emulator falls further than this behind the
+// target clock (e.g. the host was busy), we stop trying
+// to catch up and start counting again from now
+const maxClockLag = 100 * time.Millisecond
+
+// Clock keeps track of the cycles executed by the CPU
+// and throttles execution to a target frequency
+type Clock struct {
+	Hz     uint64 // Target frequency, ClockUnlimited runs as fast as possible
+	Cycles uint64 // Total cycles executed since power on
+
+	startTime   time.Time
+	startCycles uint64
+
+	sampleTime   time.Time
+	sampleCycles uint64
+}
+
+// NewClock creates a new clock running at the specified
+// frequency
+func NewClock(hz uint64) *Clock {
+	result := &Clock{Hz: hz}
+	result.Restart()
+	return result
+}
+
+// ParseClockSpeed converts a clock speed such as "1MHz",
+// "500kHz", "2000000" or "unlimited" into Hz
+func ParseClockSpeed(s string) (uint64, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	if str == "unlimited" || str == "max" || str == "0" {
+		return ClockUnlimited, nil
+	}
+
+	multiplier := 1.0
+	switch {
+	case strings.HasSuffix(str, "mhz"):
+		multiplier = 1000000
+		str = strings.TrimSuffix(str, "mhz")
+	case strings.HasSuffix(str, "khz"):
+		multiplier = 1000
+		str = strings.TrimSuffix(str, "khz")
+	case strings.HasSuffix(str, "hz"):
+		str = strings.TrimSuffix(str, "hz")
+	}
+
+	// NaN fails value > 0
+	value, err := strconv.ParseFloat(strings.TrimSpace(str), 64)
+	if err != nil || !(value > 0) || math.IsInf(value, 1) {
+		return 0, fmt.Errorf("Invalid clock speed '%s'", s)
+	}
+
+	// Anything below 1Hz would be 0, which means unlimited
+	hz := value * multiplier
+	if hz < 1 {
+		return 0, fmt.Errorf("Clock speed '%s' is below 1Hz", s)
+	}
+	return uint64(hz), nil
+}
+
+// Restart resets the point from which the clock measures
+// its progress, e.g. after the emulator has been paused
+func (c *Clock) Restart() {
+	c.startTime = time.Now()
+	c.startCycles = c.Cycles
+	c.sampleTime = c.startTime
+	c.sampleCycles = c.Cycles
+}
+
+// SetSpeed changes the target frequency
+func (c *Clock) SetSpeed(hz uint64) {
+	c.Hz = hz
+	c.Restart()
+}
+
+// Tick adds the specified number of cycles
+func (c *Clock) Tick(cycles uint8) {
+	c.Cycles += uint64(cycles)
+}
+
+// SliceCycles returns the number of cycles to run before
+// checking in with the throttle again, 0 if unlimited
+func (c *Clock) SliceCycles() uint64 {
+	if c.Hz == ClockUnlimited {
+		return 0
+	}
+
+	// Roughly a millisecond's worth of work
+	result := c.Hz / 1000
+	if result == 0 {
+		result = 1
+	}
+	return result
+}
+
+// Delay returns how long the caller should wait so that
+// the cycles executed so far match the target frequency
+func (c *Clock) Delay() time.Duration {
+	if c.Hz == ClockUnlimited {
+		return 0
+	}
+
+	target := time.Duration(float64(c.Cycles-c.startCycles) * float64(time.Second) / float64(c.Hz))
+	elapsed := time.Since(c.startTime)
+	if elapsed-target > maxClockLag {
+		c.startTime = time.Now()
+		c.startCycles = c.Cycles
+		return 0
+	}
+
+	return target - elapsed
+}
+
+// EffectiveMHz returns the speed the CPU actually ran at
+// since the last time this was called
+func (c *Clock) EffectiveMHz() float64 {
+	now := time.Now()
+	elapsed := now.Sub(c.sampleTime).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+
+	result := float64(c.Cycles-c.sampleCycles) / elapsed / 1000000
+	c.sampleTime = now
+	c.sampleCycles = c.Cycles
+	return result
+}
@@ -0,0 +1,45 @@
+package emulator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseClockSpeed(t *testing.T) {
+	tests := []struct {
+		s   string
+		hz  uint64
+		err string
+	}{
+		{"1MHz", Clock1MHz, ""},
+		{" 2mhz ", Clock2MHz, ""},
+		{"500kHz", 500000, ""},
+		{"1.5 MHz", 1500000, ""},
+		{"2000000", Clock2MHz, ""},
+		{"10Hz", 10, ""},
+		{"1", 1, ""},
+		{"unlimited", ClockUnlimited, ""},
+		{"max", ClockUnlimited, ""},
+		{"0", ClockUnlimited, ""},
+
+		{"0.5", 0, "below 1Hz"},
+		{"0.0001kHz", 0, "below 1Hz"},
+		{"0Hz", 0, "Invalid clock speed"},
+		{"-1MHz", 0, "Invalid clock speed"},
+		{"fast", 0, "Invalid clock speed"},
+		{"NaN", 0, "Invalid clock speed"},
+		{"inf", 0, "Invalid clock speed"},
+	}
+
+	for _, test := range tests {
+		hz, err := ParseClockSpeed(test.s)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%q: %s", test.s, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%q: error %v, expected it to contain %q", test.s, err, test.err)
+		case hz != test.hz:
+			t.Errorf("%q: %d Hz, expected %d", test.s, hz, test.hz)
+		}
+	}
+}
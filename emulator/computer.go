@@ -1,116 +1,379 @@
 package emulator
 
 import (
-	"github.com/ariejan/i6502"
-	"github.com/hculpan/go6502/screen"
+	"io"
+	"log"
+	"sync"
+	"time"
 )
 
+// Command is a request sent to the emulator goroutine
+type Command int
+
+// Commands understood by the emulator goroutine
+const (
+	CommandStart     Command = iota // Resume normal processing
+	CommandPause                    // Stop and wait for single steps
+	CommandStep                     // Process the next instruction while paused
+	CommandReset                    // Reset the CPU and devices
+	CommandTerminate                // Stop the emulator goroutine
+)
+
+// Number of instructions executed between checks
+// of the command channel
+const batchSize = 1000
+
 // Emulator encapsulates the 6502 computer
 type Emulator struct {
-	CPU        *i6502.Cpu
+	CPU        *CPU
 	Active     bool
-	Key        rune
-	KeyWaiting bool
 	SingleStep bool
 
+	// BreakpointHandler, if set, is called with the PC before each
+	// instruction.  Returning true pauses the emulator in single
+	// step mode.
+	BreakpointHandler func(address uint16) bool
+
+	display           Display
 	keyboardInterface *KeyboardInterface
+	ram               []*Ram
+	devices           map[string]Memory
+	tickers           []Ticker
+	resetters         []Resetter
+	closers           []io.Closer
+	interrupts        *InterruptController
+	clock             *Clock
+
+	resetAddress    uint16 // Where to start after a reset, if useResetAddress
+	useResetAddress bool
+
+	romWrites     ROMWriteMode
+	romWriteFault bool   // Set when a write to ROM should pause the emulator
+	instructionPC uint16 // Address of the instruction being executed
+
+	mu       sync.Mutex
+	commands chan Command
+	stopped  chan bool
 
 	stepWait bool
-	done     bool
+	resumed  bool
 }
 
-// NewEmulator create a new emulator
-func NewEmulator(scr *screen.Screen) *Emulator {
-	result := &Emulator{}
-
-	ram1, err := i6502.NewRam(0x8000) // 32k
+// NewEmulator create a new emulator of the default machine using the
+// specified CPU variant, with screen output going to the display
+func NewEmulator(scr Display, variant Variant) *Emulator {
+	result, err := NewEmulatorFromMachine(scr, variant, DefaultMachine())
 	if err != nil {
 		panic(err)
 	}
-	scri, err := NewScreenInterface(0x8000, scr)
-	if err != nil {
-		panic(err)
+	return result
+}
+
+// NewEmulatorFromMachine creates a new emulator with the memory map
+// from the machine description
+func NewEmulatorFromMachine(scr Display, variant Variant, machine *Machine) (*Emulator, error) {
+	if err := machine.Validate(); err != nil {
+		return nil, err
 	}
-	ram2, err := i6502.NewRam(0x7C00) // 31k
-	if err != nil {
-		panic(err)
+
+	result := &Emulator{
+		display:    scr,
+		devices:    make(map[string]Memory),
+		interrupts: NewInterruptController(),
 	}
-	result.keyboardInterface = NewKeyboardInterface()
 
-	bus, _ := i6502.NewAddressBus()
-	bus.Attach(ram1, 0x0000)
-	bus.Attach(scri, 0x8000)
-	bus.Attach(result.keyboardInterface, 0x8001)
-	bus.Attach(ram2, 0x8400)
-	result.CPU, _ = i6502.NewCpu(bus)
+	bus, _ := NewAddressBus()
+	for _, d := range machine.Devices {
+		memory, err := deviceTypes[d.Type].create(result, machine, d)
+		if err != nil {
+			return nil, err
+		}
+		bus.Attach(memory, uint16(d.Address))
+
+		if d.Name != "" {
+			result.devices[d.Name] = memory
+		}
+		if ticker, ok := memory.(Ticker); ok {
+			result.tickers = append(result.tickers, ticker)
+		}
+		if resetter, ok := memory.(Resetter); ok {
+			result.resetters = append(result.resetters, resetter)
+		}
+		if closer, ok := memory.(io.Closer); ok {
+			result.closers = append(result.closers, closer)
+		}
+
+		name := d.Name
+		if name == "" {
+			name = d.String()
+		}
+		if source, ok := memory.(InterruptSource); ok {
+			result.interrupts.AddSource(name, LineIRQ, source.IRQ)
+		}
+		if source, ok := memory.(NMISource); ok {
+			result.interrupts.AddSource(name, LineNMI, source.NMI)
+		}
+	}
+	result.CPU = NewCPU(bus, variant)
 	result.Active = false
 
 	result.SingleStep = false
 	result.stepWait = false
-	result.KeyWaiting = false
 
-	return result
+	result.clock = NewClock(Clock1MHz)
+	result.commands = make(chan Command)
+
+	return result, nil
 }
 
 // GetCPU returns a reference to the CPU
-func (e Emulator) GetCPU() *i6502.Cpu {
+func (e *Emulator) GetCPU() *CPU {
 	return e.CPU
 }
 
+// Device returns the device given the name in the machine
+// description, e.g. to hook up to a VIA's ports
+func (e *Emulator) Device(name string) (Memory, bool) {
+	result, ok := e.devices[name]
+	return result, ok
+}
+
+// Synchronize calls the function while the emulator goroutine
+// is between instructions, so that it can safely use the devices
+func (e *Emulator) Synchronize(f func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f()
+}
+
+// GetRegisters returns a snapshot of the CPU registers
+func (e *Emulator) GetRegisters() Registers {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.CPU.Registers
+}
+
 // ReadMemory allows the caller to read data from memory
-func (e Emulator) ReadMemory(address uint16) uint8 {
-	return e.CPU.Bus.ReadByte(address)
+func (e *Emulator) ReadMemory(address uint16) uint8 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.CPU.Bus.Read(address)
 }
 
 // WriteMemory allows the caller to write data to a specific
 // location in memory
 func (e *Emulator) WriteMemory(address uint16, data uint8) {
-	e.CPU.Bus.WriteByte(address, data)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.CPU.Bus.Write(address, data)
 }
 
-// SetKeyWaiting sets the next key that is waiting to be
-// read by the emulator
+// LoadMemory writes to RAM or ROM on behalf of a program loader,
+// bypassing the CPU.  It returns false if there is no RAM or ROM
+// at the address.
+func (e *Emulator) LoadMemory(address uint16, data uint8) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.CPU.Bus.LoadByte(address, data)
+}
+
+// ClearRAM sets all the RAM, but not the ROM, to 0
+func (e *Emulator) ClearRAM() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, ram := range e.ram {
+		ram.Clear()
+	}
+}
+
+// SetKeyWaiting adds a key to the keyboard buffer, to be read
+// by the emulator.  It is ignored if the machine has no keyboard,
+// and dropped with a warning if the buffer is full.
 func (e *Emulator) SetKeyWaiting(k rune) {
-	e.keyboardInterface.Key = k
-	e.keyboardInterface.KeyWaiting = true
-	if e.CPU.P&0b00000100 == 0 {
-		e.CPU.Interrupt()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.keyboardInterface == nil {
+		return
+	}
+	if !e.keyboardInterface.PushKey(k) {
+		log.Printf("Keyboard buffer full, dropped key $%02X", k)
 	}
 }
 
-// Terminate terminates the emulator
+// IsKeyWaiting returns true until all the keys passed to
+// SetKeyWaiting have been read by the CPU
+func (e *Emulator) IsKeyWaiting() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.keyboardInterface != nil && e.keyboardInterface.Pending() > 0
+}
+
+// IsKeyBufferFull returns true if the next key passed to
+// SetKeyWaiting would be dropped
+func (e *Emulator) IsKeyBufferFull() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.keyboardInterface != nil && e.keyboardInterface.IsFull()
+}
+
+// AssertInterrupt pulls the line low on behalf of the named
+// device, e.g. an NMI button, until ReleaseInterrupt is called
+func (e *Emulator) AssertInterrupt(line InterruptLine, device string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.interrupts.Assert(line, device)
+}
+
+// ReleaseInterrupt lets go of a line held by AssertInterrupt
+func (e *Emulator) ReleaseInterrupt(line InterruptLine, device string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.interrupts.Release(line, device)
+}
+
+// GetInterrupts returns the devices pulling the interrupt
+// lines low, for the debugger
+func (e *Emulator) GetInterrupts() []InterruptState {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.interrupts.Asserted()
+}
+
+// Fault returns the error that stopped the CPU, if any
+func (e *Emulator) Fault() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.CPU.Fault
+}
+
+// Disassemble returns the instruction at the specified address
+// in assembler syntax, along with the address of the following
+// instruction
+func (e *Emulator) Disassemble(address uint16) (string, uint16) {
+	in := DecodeInstruction(e, address)
+	return in.String(), address + uint16(in.Size)
+}
+
+// SetClockSpeed sets the frequency, in Hz, the emulator
+// is throttled to.  ClockUnlimited runs as fast as possible.
+func (e *Emulator) SetClockSpeed(hz uint64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.clock.SetSpeed(hz)
+}
+
+// GetClockSpeed returns the target frequency in Hz
+func (e *Emulator) GetClockSpeed() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.clock.Hz
+}
+
+// GetCycles returns the number of cycles executed
+// since power on
+func (e *Emulator) GetCycles() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.clock.Cycles
+}
+
+// EffectiveMHz returns the speed the emulator actually
+// ran at since the last call
+func (e *Emulator) EffectiveMHz() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.clock.EffectiveMHz()
+}
+
+// SetResetAddress makes the CPU start at the address after a
+// reset, rather than at the reset vector, e.g. for a program
+// loaded from a file with a start address
+func (e *Emulator) SetResetAddress(address uint16) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resetAddress = address
+	e.useResetAddress = true
+}
+
+// UseResetVector goes back to starting at the reset
+// vector after a reset
+func (e *Emulator) UseResetVector() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.useResetAddress = false
+}
+
+// SetROMWriteMode sets what happens when the CPU writes to
+// ROM, for the ROMs that don't have their own setting
+func (e *Emulator) SetROMWriteMode(mode ROMWriteMode) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.romWrites = mode
+}
+
+// IsSingleStep returns whether the emulator is currently
+// single stepping, either because it was asked to or
+// because it reached a breakpoint
+func (e *Emulator) IsSingleStep() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.SingleStep
+}
+
+// Terminate stops the emulator goroutine and waits
+// for it to exit
 func (e *Emulator) Terminate() {
-	e.done = true
+	if !e.Active {
+		return
+	}
+	e.commands <- CommandTerminate
+	<-e.stopped
+	e.Active = false
+}
+
+// Close stops the emulator and closes the devices that hold
+// on to something outside it, such as a serial port's listener.
+// Unlike Terminate, the emulator can't be started again.
+func (e *Emulator) Close() error {
+	e.Terminate()
+
+	var result error
+	for _, c := range e.closers {
+		if err := c.Close(); err != nil && result == nil {
+			result = err
+		}
+	}
+	return result
+}
+
+// Reset resets the CPU and the devices
+func (e *Emulator) Reset() {
+	e.send(CommandReset)
 }
 
 // EnableSingleStep turns on single stepping through instructions
 func (e *Emulator) EnableSingleStep() {
-	e.SingleStep = true
-	e.stepWait = true
+	e.send(CommandPause)
 }
 
 // DisableSingleStep turns on single stepping through instructions
 // and resumes normal processing
 func (e *Emulator) DisableSingleStep() {
-	e.SingleStep = false
-	e.stepWait = false
+	e.send(CommandStart)
 }
 
 // NextStep processes the next cpu step
 // Useful only when SingleStep is enabled
 func (e *Emulator) NextStep() {
-	e.stepWait = false
+	e.send(CommandStep)
 }
 
 // Step allows the CPU to process the next
 // clock tick
 func (e *Emulator) Step() {
-	if !e.stepWait {
-		e.CPU.Step()
-		if e.SingleStep {
-			e.stepWait = true
-		}
-	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.step()
 }
 
 // StartEmulator starts the emulator in a separate goroutine
@@ -119,27 +382,172 @@ func (e *Emulator) StartEmulator() {
 		return
 	}
 	e.Active = true
-	e.done = false
+	e.stopped = make(chan bool)
 
+	e.mu.Lock()
+	e.reset()
+	e.mu.Unlock()
+	e.display.Reset()
+
+	go e.run()
+}
+
+// send hands the command to the emulator goroutine, or
+// processes it directly if the goroutine is not running
+func (e *Emulator) send(c Command) {
+	if e.Active {
+		e.commands <- c
+	} else {
+		e.processCommand(c)
+	}
+}
+
+// processCommand applies a command to the emulator state,
+// returning false if the emulator should terminate
+func (e *Emulator) processCommand(c Command) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch c {
+	case CommandStart:
+		e.SingleStep = false
+		e.stepWait = false
+		e.resumed = true
+		e.clock.Restart()
+	case CommandPause:
+		e.SingleStep = true
+		e.stepWait = true
+	case CommandStep:
+		e.stepWait = false
+		e.resumed = true
+	case CommandReset:
+		e.reset()
+	case CommandTerminate:
+		return false
+	}
+
+	return true
+}
+
+// reset resets the CPU and interrupts, with the lock held
+func (e *Emulator) reset() {
 	e.CPU.Reset()
+	if e.useResetAddress {
+		e.CPU.PC = e.resetAddress
+	}
+	for _, d := range e.resetters {
+		d.Reset()
+	}
+	e.interrupts.Reset()
+}
+
+func (e *Emulator) waiting() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.stepWait
+}
 
-	/*	go func() {
-			defer func() {
-				e.Active = false
-			}()
-			for {
-				if e.done {
-					return
-				}
-
-				if !e.stepWait {
-					e.CPU.Step()
-					if e.SingleStep {
-						e.stepWait = true
-					}
-					time.Sleep(1 * time.Microsecond)
-				}
+// step executes a single instruction, unless we are
+// waiting on a single step or have reached a breakpoint.
+// The caller must hold the lock.
+func (e *Emulator) step() bool {
+	if e.stepWait {
+		return false
+	}
+
+	if !e.resumed && e.BreakpointHandler != nil && e.BreakpointHandler(e.CPU.PC) {
+		e.SingleStep = true
+		e.stepWait = true
+		return false
+	}
+	e.resumed = false
+
+	e.instructionPC = e.CPU.PC
+	e.tick(e.CPU.Step())
+	if cycles := e.interrupts.Service(e.CPU); cycles > 0 {
+		e.tick(cycles)
+	}
+	if e.CPU.Fault != nil || e.romWriteFault {
+		// Stop so the problem can be looked at in the debugger
+		e.romWriteFault = false
+		e.SingleStep = true
+		e.stepWait = true
+		return false
+	}
+
+	if e.SingleStep {
+		e.stepWait = true
+	}
+
+	return true
+}
+
+// runBatch runs either a slice of the clock's cycles or, when
+// unthrottled, a fixed number of instructions.  It returns how
+// long to wait before running the next batch.
+func (e *Emulator) runBatch() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	slice := e.clock.SliceCycles()
+	end := e.clock.Cycles + slice
+	for i := 0; slice > 0 || i < batchSize; i++ {
+		if !e.step() || (slice > 0 && e.clock.Cycles >= end) {
+			break
+		}
+	}
+
+	return e.clock.Delay()
+}
+
+// tick advances the clock and the devices that count cycles
+func (e *Emulator) tick(cycles uint8) {
+	e.clock.Tick(cycles)
+	for _, t := range e.tickers {
+		t.Tick(cycles)
+	}
+}
+
+// romWrite is called when the CPU writes to ROM, with the lock
+// held.  The mode is the ROM's own setting, if it has one.
+func (e *Emulator) romWrite(address uint16, data byte, mode *ROMWriteMode) {
+	m := e.romWrites
+	if mode != nil {
+		m = *mode
+	}
+
+	if m == ROMWriteIgnore {
+		return
+	}
+	log.Printf("Write of $%02X to ROM at $%04X by the instruction at $%04X", data, address, e.instructionPC)
+	if m == ROMWriteBreak {
+		e.romWriteFault = true
+	}
+}
+
+func (e *Emulator) run() {
+	defer close(e.stopped)
+
+	for {
+		if e.waiting() {
+			// Nothing to do until we're told otherwise
+			if !e.processCommand(<-e.commands) {
+				return
+			}
+			continue
+		}
+
+		select {
+		case c := <-e.commands:
+			if !e.processCommand(c) {
+				return
+			}
+		default:
+			if delay := e.runBatch(); delay > 0 {
+				time.Sleep(delay)
+			} else {
+				time.Sleep(1 * time.Microsecond)
 			}
-		}()
-	*/
+		}
+	}
 }
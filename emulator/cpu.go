@@ -0,0 +1,709 @@
+package emulator
+
+import "fmt"
+
+// Fixed memory locations used by the CPU
+const (
+	StackBase   = 0x0100 // 0x0100-01FF is reserved for the stack
+	NmiVector   = 0xFFFA // 0xFFFA-FFFB
+	ResetVector = 0xFFFC // 0xFFFC-FFFD
+	IrqVector   = 0xFFFE // 0xFFFE-FFFF
+)
+
+// Status register flags
+const (
+	FlagCarry byte = 1 << iota
+	FlagZero
+	FlagInterrupt
+	FlagDecimal
+	FlagBreak
+	FlagUnused
+	FlagOverflow
+	FlagNegative
+)
+
+// Number of cycles it takes to respond to an interrupt
+const interruptCycles = 7
+
+// CPU is a native implementation of the NMOS 6502 and the
+// WDC 65C02, selected by its Variant.  The registers are
+// embedded so they can be accessed directly, e.g. cpu.A.
+type CPU struct {
+	Registers
+
+	Bus     *AddressBus
+	Variant Variant
+
+	// Fault is set when the CPU is unable to execute the
+	// instruction at PC.  Step will do nothing until it is
+	// cleared, which Reset does.
+	Fault error
+
+	Waiting bool // Set by WAI until the next interrupt
+	Stopped bool // Set by STP until the next reset
+
+	cycles  uint8 // Cycles taken by the current instruction
+	opTypes map[uint8]OpType
+}
+
+// NewCPU creates a new CPU of the specified variant, using
+// the address bus to access memory
+func NewCPU(bus *AddressBus, variant Variant) *CPU {
+	return &CPU{Bus: bus, Variant: variant, opTypes: variantOpTypes[variant]}
+}
+
+// String returns the current state of the CPU
+func (c *CPU) String() string {
+	return fmt.Sprintf("PC=%04X A=%02X X=%02X Y=%02X SP=%02X P=%08b", c.PC, c.A, c.X, c.Y, c.SP, c.P)
+}
+
+// Reset emulates the RESB pin.  The PC is loaded from the
+// reset vector and the interrupt disable flag is set.  The
+// other registers are not defined on real hardware, so we
+// clear them for convenience.
+func (c *CPU) Reset() {
+	c.PC = c.Bus.Read16(ResetVector)
+	c.P = FlagUnused | FlagInterrupt
+	c.A = 0x00
+	c.X = 0x00
+	c.Y = 0x00
+	c.SP = 0xFD
+	c.Fault = nil
+	c.Waiting = false
+	c.Stopped = false
+}
+
+// Interrupt emulates the IRQ pin.  It does not check the
+// interrupt disable flag, that's up to the caller.  Returns
+// the number of cycles taken, 0 if STP has stopped the CPU
+// as only a reset starts it again.
+func (c *CPU) Interrupt() uint8 {
+	if c.Stopped {
+		return 0
+	}
+	c.Waiting = false
+	c.interrupt(c.PC, IrqVector, false)
+	return interruptCycles
+}
+
+// NonMaskableInterrupt emulates the NMI pin.  Returns the
+// number of cycles taken, 0 if STP has stopped the CPU.
+func (c *CPU) NonMaskableInterrupt() uint8 {
+	if c.Stopped {
+		return 0
+	}
+	c.Waiting = false
+	c.interrupt(c.PC, NmiVector, false)
+	return interruptCycles
+}
+
+// Step executes the instruction pointed to by the PC and
+// returns the number of cycles it took
+func (c *CPU) Step() uint8 {
+	if c.Fault != nil {
+		return 0
+	}
+
+	if c.Waiting || c.Stopped {
+		// The clock keeps running while we do nothing
+		return 1
+	}
+
+	opcode := c.Bus.Read(c.PC)
+	opType, ok := c.opTypes[opcode]
+	if !ok {
+		c.Fault = fmt.Errorf("Undocumented opcode 0x%02X at 0x%04X", opcode, c.PC)
+		return 0
+	}
+
+	in := Instruction{OpType: opType, Address: c.PC}
+	switch opType.Size {
+	case 2:
+		in.Op8 = c.Bus.Read(c.PC + 1)
+	case 3:
+		in.Op16 = c.Bus.Read16(c.PC + 1)
+	}
+
+	c.PC += uint16(opType.Size)
+	c.cycles = opType.Cycles
+	c.execute(in)
+
+	return c.cycles
+}
+
+func (c *CPU) execute(in Instruction) {
+	switch in.opcodeID {
+	case nop:
+		if in.addressingID != implied && in.addressingID != immediate {
+			// Still works out the address, which may cost a cycle
+			c.address(in)
+		}
+	case adc:
+		c.adc(c.operand(in))
+	case sbc:
+		c.sbc(c.operand(in))
+	case and:
+		c.setA(c.A & c.operand(in))
+	case ora:
+		c.setA(c.A | c.operand(in))
+	case eor:
+		c.setA(c.A ^ c.operand(in))
+	case lda:
+		c.setA(c.operand(in))
+	case ldx:
+		c.setX(c.operand(in))
+	case ldy:
+		c.setY(c.operand(in))
+	case sta:
+		c.Bus.Write(c.address(in), c.A)
+	case stx:
+		c.Bus.Write(c.address(in), c.X)
+	case sty:
+		c.Bus.Write(c.address(in), c.Y)
+	case stz:
+		c.Bus.Write(c.address(in), 0)
+	case trb:
+		address := c.address(in)
+		value := c.Bus.Read(address)
+		c.setFlag(FlagZero, c.A&value == 0)
+		c.Bus.Write(address, value&^c.A)
+	case tsb:
+		address := c.address(in)
+		value := c.Bus.Read(address)
+		c.setFlag(FlagZero, c.A&value == 0)
+		c.Bus.Write(address, value|c.A)
+	case rmb:
+		address := c.address(in)
+		c.Bus.Write(address, c.Bus.Read(address)&^(1<<in.bitNumber()))
+	case smb:
+		address := c.address(in)
+		c.Bus.Write(address, c.Bus.Read(address)|(1<<in.bitNumber()))
+	case bbr:
+		value := c.Bus.Read(uint16(byte(in.Op16)))
+		c.branchTo(byte(in.Op16>>8), value&(1<<in.bitNumber()) == 0)
+	case bbs:
+		value := c.Bus.Read(uint16(byte(in.Op16)))
+		c.branchTo(byte(in.Op16>>8), value&(1<<in.bitNumber()) != 0)
+	case tax:
+		c.setX(c.A)
+	case tay:
+		c.setY(c.A)
+	case txa:
+		c.setA(c.X)
+	case tya:
+		c.setA(c.Y)
+	case tsx:
+		c.setX(c.SP)
+	case txs:
+		c.SP = c.X
+	case inx:
+		c.setX(c.X + 1)
+	case iny:
+		c.setY(c.Y + 1)
+	case dex:
+		c.setX(c.X - 1)
+	case dey:
+		c.setY(c.Y - 1)
+	case inc:
+		c.modify(in, func(v byte) byte { return v + 1 })
+	case dec:
+		c.modify(in, func(v byte) byte { return v - 1 })
+	case asl:
+		c.modify(in, c.asl)
+	case lsr:
+		c.modify(in, c.lsr)
+	case rol:
+		c.modify(in, c.rol)
+	case ror:
+		c.modify(in, c.ror)
+	case cmp:
+		c.compare(c.A, c.operand(in))
+	case cpx:
+		c.compare(c.X, c.operand(in))
+	case cpy:
+		c.compare(c.Y, c.operand(in))
+	case bit:
+		value := c.operand(in)
+		if in.addressingID != immediate {
+			// BIT #imm only affects the Z flag
+			c.setFlag(FlagNegative, value&0x80 != 0)
+			c.setFlag(FlagOverflow, value&0x40 != 0)
+		}
+		c.setFlag(FlagZero, c.A&value == 0)
+	case sec:
+		c.setFlag(FlagCarry, true)
+	case sed:
+		c.setFlag(FlagDecimal, true)
+	case sei:
+		c.setFlag(FlagInterrupt, true)
+	case clc:
+		c.setFlag(FlagCarry, false)
+	case cld:
+		c.setFlag(FlagDecimal, false)
+	case cli:
+		c.setFlag(FlagInterrupt, false)
+	case clv:
+		c.setFlag(FlagOverflow, false)
+	case bra:
+		c.branch(in, true)
+	case bcc:
+		c.branch(in, c.P&FlagCarry == 0)
+	case bcs:
+		c.branch(in, c.P&FlagCarry != 0)
+	case bne:
+		c.branch(in, c.P&FlagZero == 0)
+	case beq:
+		c.branch(in, c.P&FlagZero != 0)
+	case bpl:
+		c.branch(in, c.P&FlagNegative == 0)
+	case bmi:
+		c.branch(in, c.P&FlagNegative != 0)
+	case bvc:
+		c.branch(in, c.P&FlagOverflow == 0)
+	case bvs:
+		c.branch(in, c.P&FlagOverflow != 0)
+	case pha:
+		c.push(c.A)
+	case pla:
+		c.setA(c.pop())
+	case phx:
+		c.push(c.X)
+	case plx:
+		c.setX(c.pop())
+	case phy:
+		c.push(c.Y)
+	case ply:
+		c.setY(c.pop())
+	case php:
+		c.push(c.P | FlagBreak | FlagUnused)
+	case plp:
+		c.setP(c.pop())
+	case jmp:
+		c.PC = c.address(in)
+	case jsr:
+		c.push16(c.PC - 1)
+		c.PC = in.Op16
+	case rts:
+		c.PC = c.pop16() + 1
+	case rti:
+		c.setP(c.pop())
+		c.PC = c.pop16()
+	case brk:
+		// BRK skips the byte following the opcode
+		c.interrupt(c.PC+1, IrqVector, true)
+	case wai:
+		c.Waiting = true
+	case stp:
+		c.Stopped = true
+	case slo:
+		c.setA(c.A | c.modify(in, c.asl))
+	case rla:
+		c.setA(c.A & c.modify(in, c.rol))
+	case sre:
+		c.setA(c.A ^ c.modify(in, c.lsr))
+	case rra:
+		c.adc(c.modify(in, c.ror))
+	case dcp:
+		c.compare(c.A, c.modify(in, func(v byte) byte { return v - 1 }))
+	case isc:
+		c.sbc(c.modify(in, func(v byte) byte { return v + 1 }))
+	case sax:
+		c.Bus.Write(c.address(in), c.A&c.X)
+	case lax:
+		c.setA(c.operand(in))
+		c.X = c.A
+	case las:
+		c.SP &= c.operand(in)
+		c.setA(c.SP)
+		c.X = c.SP
+	case anc:
+		c.setA(c.A & in.Op8)
+		c.setFlag(FlagCarry, c.A&0x80 != 0)
+	case alr:
+		c.setA(c.lsr(c.A & in.Op8))
+	case arr:
+		c.arr(in.Op8)
+	case sbx:
+		value := c.A & c.X
+		c.setFlag(FlagCarry, value >= in.Op8)
+		c.setX(value - in.Op8)
+	case ane:
+		c.setA((c.A | 0xEE) & c.X & in.Op8)
+	case lxa:
+		c.setA((c.A | 0xEE) & in.Op8)
+		c.X = c.A
+	case sha:
+		c.storeHigh(in, c.A&c.X)
+	case shx:
+		c.storeHigh(in, c.X)
+	case shy:
+		c.storeHigh(in, c.Y)
+	case tas:
+		c.SP = c.A & c.X
+		c.storeHigh(in, c.SP)
+	case jam:
+		c.PC = in.Address
+		c.Fault = fmt.Errorf("CPU jammed by opcode 0x%02X at 0x%04X", in.Opcode, in.Address)
+	default:
+		c.Fault = fmt.Errorf("Unimplemented instruction %s at 0x%04X", in.GetInstructionName(), in.Address)
+	}
+}
+
+// address returns the effective address of the instruction's
+// operand, adding a cycle for page crossings where needed
+func (c *CPU) address(in Instruction) uint16 {
+	switch in.addressingID {
+	case zeropage:
+		return uint16(in.Op8)
+	case zeropageX:
+		return uint16(in.Op8 + c.X)
+	case zeropageY:
+		return uint16(in.Op8 + c.Y)
+	case absolute:
+		return in.Op16
+	case absoluteX:
+		return c.indexed(in, in.Op16, c.X)
+	case absoluteY:
+		return c.indexed(in, in.Op16, c.Y)
+	case indirect:
+		if c.Variant.IsCMOS() {
+			return c.Bus.Read16(in.Op16)
+		}
+		// The NMOS 6502 doesn't carry into the high byte when
+		// fetching the pointer, so JMP ($xxFF) reads the high
+		// byte from $xx00
+		hi := (in.Op16 & 0xFF00) | uint16(byte(in.Op16)+1)
+		return uint16(c.Bus.Read(in.Op16)) | uint16(c.Bus.Read(hi))<<8
+	case indirectX:
+		return c.readZeroPage16(in.Op8 + c.X)
+	case indirectY:
+		return c.indexed(in, c.readZeroPage16(in.Op8), c.Y)
+	case zeropageIndirect:
+		return c.readZeroPage16(in.Op8)
+	case absoluteIndexedIndirect:
+		return c.Bus.Read16(in.Op16 + uint16(c.X))
+	}
+
+	return 0
+}
+
+func (c *CPU) indexed(in Instruction, base uint16, index byte) uint16 {
+	result := base + uint16(index)
+	if c.hasPageCrossPenalty(in) && pageCrossed(base, result) {
+		c.cycles++
+	}
+	return result
+}
+
+func (c *CPU) readZeroPage16(address byte) uint16 {
+	return uint16(c.Bus.Read(uint16(address))) | uint16(c.Bus.Read(uint16(address+1)))<<8
+}
+
+// operand returns the value the instruction operates on
+func (c *CPU) operand(in Instruction) byte {
+	switch in.addressingID {
+	case immediate:
+		return in.Op8
+	case accumulator:
+		return c.A
+	}
+
+	return c.Bus.Read(c.address(in))
+}
+
+// modify performs a read-modify-write on the instruction's
+// operand, which is either memory or the accumulator, and
+// returns the new value
+func (c *CPU) modify(in Instruction, f func(byte) byte) byte {
+	if in.addressingID == accumulator {
+		c.setA(f(c.A))
+		return c.A
+	}
+
+	address := c.address(in)
+	result := f(c.Bus.Read(address))
+	c.Bus.Write(address, result)
+	c.setNZ(result)
+	return result
+}
+
+// storeHigh implements the unstable SHA, SHX, SHY and TAS
+// stores, which AND the value with the high byte of the
+// base address plus one
+func (c *CPU) storeHigh(in Instruction, value byte) {
+	base := in.Op16
+	if in.addressingID == indirectY {
+		base = c.readZeroPage16(in.Op8)
+	}
+	c.Bus.Write(c.address(in), value&(byte(base>>8)+1))
+}
+
+func (c *CPU) branch(in Instruction, condition bool) {
+	c.branchTo(in.Op8, condition)
+}
+
+// branchTo adds the signed offset to the PC if the condition
+// holds, with an extra cycle for the branch being taken and
+// another if it lands on a different page
+func (c *CPU) branchTo(offset byte, condition bool) {
+	if !condition {
+		return
+	}
+
+	target := c.PC + uint16(int8(offset))
+	c.cycles++
+	if pageCrossed(c.PC, target) {
+		c.cycles++
+	}
+	c.PC = target
+}
+
+func (c *CPU) interrupt(pc uint16, vector uint16, brk bool) {
+	c.push16(pc)
+	if brk {
+		c.push(c.P | FlagBreak | FlagUnused)
+	} else {
+		c.push((c.P &^ FlagBreak) | FlagUnused)
+	}
+	c.setFlag(FlagInterrupt, true)
+	if c.Variant.IsCMOS() {
+		c.setFlag(FlagDecimal, false)
+	}
+	c.PC = c.Bus.Read16(vector)
+}
+
+func (c *CPU) adc(value byte) {
+	if c.P&FlagDecimal != 0 {
+		c.adcDecimal(value)
+	} else {
+		c.adcBinary(value)
+	}
+}
+
+func (c *CPU) adcBinary(value byte) {
+	sum := uint16(c.A) + uint16(value) + uint16(c.P&FlagCarry)
+	result := byte(sum)
+	c.setFlag(FlagCarry, sum > 0xFF)
+	c.setFlag(FlagOverflow, (c.A^result)&(value^result)&0x80 != 0)
+	c.setA(result)
+}
+
+// adcDecimal follows the behavior described in Bruce Clark's
+// "Decimal Mode" tutorial.  On the NMOS 6502 Z reflects the
+// binary sum, while N and V are taken before the high nibble
+// is adjusted.  The 65C02 sets N and Z from the result, at
+// the cost of an extra cycle.
+func (c *CPU) adcDecimal(value byte) {
+	a, b, carry := int(c.A), int(value), int(c.P&FlagCarry)
+
+	lo := (a & 0x0F) + (b & 0x0F) + carry
+	if lo >= 0x0A {
+		lo = ((lo + 0x06) & 0x0F) + 0x10
+	}
+	sum := (a & 0xF0) + (b & 0xF0) + lo
+	signed := int(int8(a&0xF0)) + int(int8(b&0xF0)) + lo
+
+	c.setFlag(FlagZero, byte(a+b+carry) == 0)
+	c.setFlag(FlagNegative, sum&0x80 != 0)
+	c.setFlag(FlagOverflow, signed < -128 || signed > 127)
+
+	if sum >= 0xA0 {
+		sum += 0x60
+	}
+	c.setFlag(FlagCarry, sum >= 0x100)
+	c.A = byte(sum)
+
+	if c.Variant.IsCMOS() {
+		c.setNZ(c.A)
+		c.cycles++
+	}
+}
+
+func (c *CPU) sbc(value byte) {
+	if c.P&FlagDecimal != 0 {
+		c.sbcDecimal(value)
+	} else {
+		c.adcBinary(^value)
+	}
+}
+
+// sbcDecimal follows the NMOS behavior, where all the flags
+// are the same as for a binary subtraction.  The 65C02 works
+// the result out differently, sets N and Z from it, and
+// takes an extra cycle.
+func (c *CPU) sbcDecimal(value byte) {
+	a, b, borrow := int(c.A), int(value), 1-int(c.P&FlagCarry)
+
+	lo := (a & 0x0F) - (b & 0x0F) - borrow
+	var result int
+	if c.Variant.IsCMOS() {
+		result = a - b - borrow
+		if result < 0 {
+			result -= 0x60
+		}
+		if lo < 0 {
+			result -= 0x06
+		}
+	} else {
+		if lo < 0 {
+			lo = ((lo - 0x06) & 0x0F) - 0x10
+		}
+		result = (a & 0xF0) - (b & 0xF0) + lo
+		if result < 0 {
+			result -= 0x60
+		}
+	}
+
+	c.adcBinary(^value)
+	c.A = byte(result)
+
+	if c.Variant.IsCMOS() {
+		c.setNZ(c.A)
+		c.cycles++
+	}
+}
+
+// arr is the undocumented AND followed by ROR, whose flags
+// come from the adder, and which does a decimal adjust of
+// its own when the D flag is set
+func (c *CPU) arr(value byte) {
+	t := c.A & value
+	carry := c.P & FlagCarry
+	result := t>>1 | carry<<7
+
+	if c.P&FlagDecimal == 0 {
+		c.setA(result)
+		c.setFlag(FlagCarry, result&0x40 != 0)
+		c.setFlag(FlagOverflow, (result>>6^result>>5)&0x01 != 0)
+		return
+	}
+
+	c.setFlag(FlagNegative, carry != 0)
+	c.setFlag(FlagZero, result == 0)
+	c.setFlag(FlagOverflow, (t^result)&0x40 != 0)
+
+	lo, hi := t&0x0F, t>>4
+	if lo+(lo&0x01) > 5 {
+		result = (result & 0xF0) | ((result + 6) & 0x0F)
+	}
+	c.setFlag(FlagCarry, hi+(hi&0x01) > 5)
+	if c.P&FlagCarry != 0 {
+		result += 0x60
+	}
+	c.A = result
+}
+
+func (c *CPU) compare(register byte, value byte) {
+	c.setFlag(FlagCarry, register >= value)
+	c.setNZ(register - value)
+}
+
+func (c *CPU) asl(value byte) byte {
+	c.setFlag(FlagCarry, value&0x80 != 0)
+	return value << 1
+}
+
+func (c *CPU) lsr(value byte) byte {
+	c.setFlag(FlagCarry, value&0x01 != 0)
+	return value >> 1
+}
+
+func (c *CPU) rol(value byte) byte {
+	carry := c.P & FlagCarry
+	c.setFlag(FlagCarry, value&0x80 != 0)
+	return value<<1 | carry
+}
+
+func (c *CPU) ror(value byte) byte {
+	carry := c.P & FlagCarry
+	c.setFlag(FlagCarry, value&0x01 != 0)
+	return value>>1 | carry<<7
+}
+
+func (c *CPU) push(value byte) {
+	c.Bus.Write(StackBase|uint16(c.SP), value)
+	c.SP--
+}
+
+func (c *CPU) pop() byte {
+	c.SP++
+	return c.Bus.Read(StackBase | uint16(c.SP))
+}
+
+func (c *CPU) push16(value uint16) {
+	c.push(byte(value >> 8))
+	c.push(byte(value))
+}
+
+func (c *CPU) pop16() uint16 {
+	lo := uint16(c.pop())
+	hi := uint16(c.pop())
+	return hi<<8 | lo
+}
+
+// pageCrossed returns true if the two addresses are on
+// different pages
+func pageCrossed(a, b uint16) bool {
+	return a&0xFF00 != b&0xFF00
+}
+
+// hasPageCrossPenalty returns true for instructions that take an
+// extra cycle when indexing crosses a page boundary.  Stores and
+// read-modify-write instructions always take the longer path, which
+// is already reflected in their base cycle count.
+func (o OpType) hasPageCrossPenalty() bool {
+	switch o.opcodeID {
+	case adc, and, bit, cmp, eor, lda, ldx, ldy, ora, sbc, lax, las, nop:
+		return true
+	}
+
+	return false
+}
+
+// hasPageCrossPenalty adds the 65C02 shifts and rotates, which
+// only take the longer path when they have to
+func (c *CPU) hasPageCrossPenalty(in Instruction) bool {
+	if c.Variant.IsCMOS() && in.addressingID == absoluteX {
+		switch in.opcodeID {
+		case asl, lsr, rol, ror:
+			return true
+		}
+	}
+
+	return in.hasPageCrossPenalty()
+}
+
+func (c *CPU) setFlag(flag byte, on bool) {
+	if on {
+		c.P |= flag
+	} else {
+		c.P &^= flag
+	}
+}
+
+// setP sets the status register from a value pulled off the
+// stack.  The break flag only exists on the stack, and the
+// unused flag always reads as set.
+func (c *CPU) setP(value byte) {
+	c.P = (value &^ FlagBreak) | FlagUnused
+}
+
+func (c *CPU) setNZ(value byte) {
+	c.setFlag(FlagZero, value == 0)
+	c.setFlag(FlagNegative, value&0x80 != 0)
+}
+
+func (c *CPU) setA(value byte) {
+	c.A = value
+	c.setNZ(value)
+}
+
+func (c *CPU) setX(value byte) {
+	c.X = value
+	c.setNZ(value)
+}
+
+func (c *CPU) setY(value byte) {
+	c.Y = value
+	c.setNZ(value)
+}
@@ -0,0 +1,48 @@
+package emulator
+
+import "testing"
+
+// TestStopIgnoresInterrupts checks that only a reset starts a
+// CPU stopped by STP, while WAI ends with any interrupt
+func TestStopIgnoresInterrupts(t *testing.T) {
+	const (
+		stp = 0xDB
+		wai = 0xCB
+	)
+
+	tests := []struct {
+		name      string
+		opcode    byte
+		interrupt func(c *CPU) uint8
+		taken     bool
+	}{
+		{"STP then IRQ", stp, (*CPU).Interrupt, false},
+		{"STP then NMI", stp, (*CPU).NonMaskableInterrupt, false},
+		{"WAI then IRQ", wai, (*CPU).Interrupt, true},
+		{"WAI then NMI", wai, (*CPU).NonMaskableInterrupt, true},
+	}
+
+	for _, test := range tests {
+		cpu := newTestCPU(Variant65C02)
+		cpu.Bus.Write16(ResetVector, testProgram)
+		cpu.Bus.Write16(IrqVector, testIRQHandler)
+		cpu.Bus.Write16(NmiVector, testNMIHandler)
+		cpu.Bus.Write(testProgram, test.opcode)
+		cpu.Reset()
+		cpu.Step()
+		sp := cpu.SP
+
+		cycles := test.interrupt(cpu)
+		if taken := cycles > 0; taken != test.taken {
+			t.Errorf("%s: interrupt taken is %t", test.name, taken)
+		}
+		if !test.taken && (cpu.PC != testProgram+1 || cpu.SP != sp || !cpu.Stopped) {
+			t.Errorf("%s: stopped CPU changed, PC=$%04X SP=$%02X", test.name, cpu.PC, cpu.SP)
+		}
+
+		cpu.Reset()
+		if cpu.Stopped || cpu.Waiting || cpu.PC != testProgram {
+			t.Errorf("%s: reset didn't start the CPU", test.name)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package emulator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+
+	"github.com/hculpan/go6502/serial"
+)
+
+// deviceType creates one kind of device in a machine description
+type deviceType struct {
+	size        int // Fixed size of the device, 0 if it is configured
+	defaultSize int // Size when a configurable device doesn't give one
+	create      func(e *Emulator, m *Machine, d DeviceConfig) (Memory, error)
+}
+
+// deviceTypes are the devices that can appear in a machine
+// description, keyed by their type
+var deviceTypes = map[string]deviceType{
+	"ram":      {create: newRAMDevice},
+	"rom":      {create: newROMDevice},
+	"screen":   {size: 1, create: newScreenDevice},
+	"keyboard": {defaultSize: 1, create: newKeyboardDevice},
+	"via":      {size: 16, create: newVIADevice},
+	"acia":     {size: 4, create: newACIADevice},
+}
+
+func newRAMDevice(e *Emulator, m *Machine, d DeviceConfig) (Memory, error) {
+	ram, err := NewRam(int(d.Size))
+	if err != nil {
+		return nil, err
+	}
+	e.ram = append(e.ram, ram)
+	return ram, nil
+}
+
+// newROMDevice creates read-only memory filled from the "file"
+// option, if set.  The file can be smaller than the ROM, in which
+// case it is loaded at the start.  The "writes" option overrides
+// the emulator's ROMWriteMode for this ROM.
+func newROMDevice(e *Emulator, m *Machine, d DeviceConfig) (Memory, error) {
+	filename, err := d.OptionString("file", "")
+	if err != nil {
+		return nil, err
+	}
+	writes, err := d.OptionString("writes", "")
+	if err != nil {
+		return nil, err
+	}
+
+	rom, err := NewRom(int(d.Size))
+	if err != nil {
+		return nil, err
+	}
+
+	var mode *ROMWriteMode
+	if writes != "" {
+		parsed, err := ParseROMWriteMode(writes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", d, err)
+		}
+		mode = &parsed
+	}
+	base := uint16(d.Address)
+	rom.WriteHandler = func(address uint16, data byte) {
+		e.romWrite(base+address, data, mode)
+	}
+
+	if filename == "" {
+		return rom, nil
+	}
+
+	data, err := ioutil.ReadFile(m.path(filename))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", d, err)
+	}
+	if len(data) > int(d.Size) {
+		return nil, fmt.Errorf("%s: %s is larger than the ROM", d, filename)
+	}
+	for i, b := range data {
+		rom.LoadByte(uint16(i), b)
+	}
+	return rom, nil
+}
+
+func newScreenDevice(e *Emulator, m *Machine, d DeviceConfig) (Memory, error) {
+	return NewScreenInterface(uint16(d.Address), e.display)
+}
+
+// newKeyboardDevice creates the keyboard, with a buffer of
+// "depth" keys.  A size of 2 adds the status register.
+func newKeyboardDevice(e *Emulator, m *Machine, d DeviceConfig) (Memory, error) {
+	if e.keyboardInterface != nil {
+		return nil, fmt.Errorf("%s: only one keyboard is supported", d)
+	}
+	depth, err := d.OptionInt("depth", DefaultKeyBufferDepth)
+	if err != nil {
+		return nil, err
+	}
+	if depth < 1 {
+		return nil, fmt.Errorf("%s: depth must be at least 1", d)
+	}
+	size := d.Size
+	if size == 0 {
+		size = 1
+	}
+	if size > 2 {
+		return nil, fmt.Errorf("%s: size must be 1, or 2 for the status register", d)
+	}
+
+	e.keyboardInterface = NewKeyboardInterface(depth, uint16(size))
+	return e.keyboardInterface, nil
+}
+
+func newVIADevice(e *Emulator, m *Machine, d DeviceConfig) (Memory, error) {
+	return NewVIA(), nil
+}
+
+// newACIADevice creates an ACIA connected to the host given by
+// the "host" option: "pty" for a pseudo-terminal, "tcp" to listen
+// on the "address" option, or nothing for an unconnected port
+func newACIADevice(e *Emulator, m *Machine, d DeviceConfig) (Memory, error) {
+	hostType, err := d.OptionString("host", "")
+	if err != nil {
+		return nil, err
+	}
+	address, err := d.OptionString("address", "127.0.0.1:6551")
+	if err != nil {
+		return nil, err
+	}
+
+	var host *serial.Host
+	switch hostType {
+	case "":
+		return NewACIA(nil), nil
+	case "pty":
+		host, err = serial.OpenPTY()
+	case "tcp":
+		host, err = serial.ListenTCP(address)
+	default:
+		return nil, fmt.Errorf("%s: unknown host '%s', expected pty or tcp", d, hostType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", d, err)
+	}
+
+	log.Printf("%s connected to %s %s", d, hostType, host.Name)
+	return NewACIA(host), nil
+}
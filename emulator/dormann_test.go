@@ -0,0 +1,148 @@
+package emulator
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/hculpan/go6502/loader"
+)
+
+// Give up on a test binary that hasn't finished after this
+// many cycles, the functional test needs about 100 million
+const maxTestCycles = 500000000
+
+// dormannTest describes one of the CPU test binaries in
+// testdata, which work like Klaus Dormann's test suite
+// (https://github.com/Klaus2m5/6502_65C02_functional_tests).
+// They are all checked in, so a missing file is a failure.
+type dormannTest struct {
+	name    string
+	file    string
+	variant Variant
+	load    uint16
+	start   uint16
+	success uint16 // Trap address reached when every test passes
+
+	// The decimal test reports failure in a memory location
+	// rather than by where it stops, if set it must be 0
+	checkError   bool
+	errorAddress uint16
+}
+
+var dormannTests = []dormannTest{
+	{
+		name:    "functional",
+		file:    "6502_functional_test.bin",
+		variant: VariantNMOS,
+		load:    0x0000,
+		start:   0x0400,
+		success: 0x3399,
+	},
+	{
+		name:    "functional 65C02",
+		file:    "6502_functional_test.bin",
+		variant: Variant65C02,
+		load:    0x0000,
+		start:   0x0400,
+		success: 0x3399,
+	},
+	{
+		name:    "65C02 opcodes",
+		file:    "65C02_opcodes_test.bin",
+		variant: Variant65C02,
+		load:    0x0400,
+		start:   0x0400,
+		success: 0x0738,
+	},
+	{
+		name:         "decimal",
+		file:         "6502_decimal_test.bin",
+		variant:      VariantNMOS,
+		load:         0x0200,
+		start:        0x0200,
+		checkError:   true,
+		errorAddress: 0x000B,
+	},
+	{
+		name:         "decimal 65C02",
+		file:         "65C02_decimal_test.bin",
+		variant:      Variant65C02,
+		load:         0x0200,
+		start:        0x0200,
+		checkError:   true,
+		errorAddress: 0x000B,
+	},
+}
+
+func TestDormann(t *testing.T) {
+	for _, test := range dormannTests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			runDormannTest(t, test)
+		})
+	}
+}
+
+func runDormannTest(t *testing.T, test dormannTest) {
+	if testing.Short() {
+		t.Skip("skipping CPU test suite in short mode")
+	}
+
+	image, err := loader.Load(filepath.Join("testdata", test.file), loader.Options{Address: int(test.load), HasAddress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cpu := newTestCPU(test.variant)
+	for _, segment := range image.Segments {
+		for i, b := range segment.Data {
+			cpu.Bus.Write(segment.Address+uint16(i), b)
+		}
+	}
+	cpu.Reset()
+	cpu.PC = test.start
+
+	var cycles uint64
+	for cycles < maxTestCycles {
+		pc := cpu.PC
+		cycles += uint64(cpu.Step())
+
+		switch {
+		case cpu.Fault != nil:
+			t.Fatalf("%s after %d cycles\n%s", cpu.Fault, cycles, formatRegisters(cpu))
+		case cpu.PC == pc || cpu.Stopped:
+			// Every test ends in a loop that jumps to itself
+			if test.checkError {
+				if result := cpu.Bus.Read(test.errorAddress); result != 0 {
+					t.Fatalf("Failed with error 0x%02X at $%04X\n%s", result, test.errorAddress, formatRegisters(cpu))
+				}
+				return
+			}
+			if cpu.PC != test.success {
+				t.Fatalf("Trapped at $%04X, expected $%04X\n%s", cpu.PC, test.success, formatRegisters(cpu))
+			}
+			return
+		}
+	}
+
+	t.Fatalf("Did not finish after %d cycles\n%s", cycles, formatRegisters(cpu))
+}
+
+// newTestCPU returns a CPU with 64k of RAM
+func newTestCPU(variant Variant) *CPU {
+	bus, _ := NewAddressBus()
+	for _, offset := range []uint16{0x0000, 0x8000} {
+		ram, _ := NewRam(0x8000)
+		bus.Attach(ram, offset)
+	}
+	return NewCPU(bus, variant)
+}
+
+// formatRegisters shows the trapped instruction and the
+// registers, to look up in the test's listing
+func formatRegisters(cpu *CPU) string {
+	in := DecodeInstruction(&Emulator{CPU: cpu}, cpu.PC)
+	return fmt.Sprintf("$%04X  %s\nA=$%02X X=$%02X Y=$%02X SP=$%02X P=$%02X",
+		cpu.PC, in.String(), cpu.A, cpu.X, cpu.Y, cpu.SP, cpu.P)
+}
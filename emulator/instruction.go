@@ -1,5 +1,7 @@
 package emulator
 
+import "fmt"
+
 // Instruction represents an actual instruction in
 // the emulator's memory
 type Instruction struct {
@@ -15,7 +17,7 @@ type Instruction struct {
 // specified address
 func DecodeInstruction(em *Emulator, addr uint16) Instruction {
 	opcode := em.ReadMemory(addr)
-	opType := NewOpType(opcode)
+	opType := NewOpType(opcode, em.CPU.Variant)
 	result := Instruction{OpType: opType, Op8: 0, Op16: 0, Address: addr}
 	switch opType.Size {
 	case 2:
@@ -33,3 +35,51 @@ func DecodeInstruction(em *Emulator, addr uint16) Instruction {
 func DecodeCurrentInstruction(em *Emulator) Instruction {
 	return DecodeInstruction(em, em.CPU.PC)
 }
+
+// String returns the instruction in assembler syntax,
+// e.g. "LDA ($12),Y"
+func (in Instruction) String() string {
+	name := in.GetInstructionName()
+	switch in.addressingID {
+	case implied:
+		return name
+	case accumulator:
+		return name + " A"
+	case immediate:
+		return fmt.Sprintf("%s #$%02X", name, in.Op8)
+	case zeropage:
+		return fmt.Sprintf("%s $%02X", name, in.Op8)
+	case zeropageX:
+		return fmt.Sprintf("%s $%02X,X", name, in.Op8)
+	case zeropageY:
+		return fmt.Sprintf("%s $%02X,Y", name, in.Op8)
+	case absolute:
+		return fmt.Sprintf("%s $%04X", name, in.Op16)
+	case absoluteX:
+		return fmt.Sprintf("%s $%04X,X", name, in.Op16)
+	case absoluteY:
+		return fmt.Sprintf("%s $%04X,Y", name, in.Op16)
+	case indirect:
+		return fmt.Sprintf("%s ($%04X)", name, in.Op16)
+	case indirectX:
+		return fmt.Sprintf("%s ($%02X,X)", name, in.Op8)
+	case indirectY:
+		return fmt.Sprintf("%s ($%02X),Y", name, in.Op8)
+	case zeropageIndirect:
+		return fmt.Sprintf("%s ($%02X)", name, in.Op8)
+	case absoluteIndexedIndirect:
+		return fmt.Sprintf("%s ($%04X,X)", name, in.Op16)
+	case relative:
+		return fmt.Sprintf("%s $%04X", name, in.branchTarget(in.Op8))
+	case zeropageRelative:
+		return fmt.Sprintf("%s $%02X,$%04X", name, byte(in.Op16), in.branchTarget(byte(in.Op16>>8)))
+	}
+
+	return fmt.Sprintf(".byte $%02X", in.Opcode)
+}
+
+// branchTarget returns the address a branch with the
+// specified offset would go to
+func (in Instruction) branchTarget(offset byte) uint16 {
+	return in.Address + uint16(in.Size) + uint16(int8(offset))
+}
@@ -0,0 +1,154 @@
+package emulator
+
+import (
+	"fmt"
+	"sort"
+)
+
+// InterruptLine is one of the CPU's interrupt inputs
+type InterruptLine int
+
+// The interrupt lines
+const (
+	LineIRQ InterruptLine = iota // Level triggered, masked by the I flag
+	LineNMI                      // Edge triggered, can't be masked
+)
+
+// String returns the name of the line
+func (l InterruptLine) String() string {
+	switch l {
+	case LineIRQ:
+		return "IRQ"
+	case LineNMI:
+		return "NMI"
+	default:
+		return fmt.Sprintf("InterruptLine(%d)", int(l))
+	}
+}
+
+// NMISource is implemented by devices that can pull
+// the NMI line low
+type NMISource interface {
+	NMI() bool
+}
+
+// InterruptState is a device pulling one of the lines low
+type InterruptState struct {
+	Line   InterruptLine
+	Device string
+}
+
+// interruptSource is a device whose line is checked at
+// every instruction boundary
+type interruptSource struct {
+	name     string
+	line     InterruptLine
+	asserted func() bool
+}
+
+// InterruptController combines the devices sharing the IRQ and
+// NMI lines and interrupts the CPU between instructions.  Devices
+// are either polled, through InterruptSource and NMISource, or
+// drive the lines themselves with Assert and Release.
+type InterruptController struct {
+	sources []interruptSource
+	held    map[InterruptState]bool
+
+	nmiLevel   bool // State of the NMI line at the last check
+	nmiPending bool // Falling edge seen, not yet taken
+}
+
+// NewInterruptController creates a controller with
+// nothing on either line
+func NewInterruptController() *InterruptController {
+	return &InterruptController{held: make(map[InterruptState]bool)}
+}
+
+// AddSource adds a device whose line is checked by calling
+// asserted before every instruction
+func (c *InterruptController) AddSource(name string, line InterruptLine, asserted func() bool) {
+	c.sources = append(c.sources, interruptSource{name, line, asserted})
+}
+
+// Assert pulls the line low on behalf of the named device,
+// until it is released
+func (c *InterruptController) Assert(line InterruptLine, device string) {
+	if line == LineNMI && !c.level(LineNMI) {
+		// Catch a pulse that is released before the next check
+		c.nmiPending = true
+	}
+	c.held[InterruptState{line, device}] = true
+}
+
+// Release lets go of a line held by Assert
+func (c *InterruptController) Release(line InterruptLine, device string) {
+	delete(c.held, InterruptState{line, device})
+}
+
+// Reset forgets about any NMI that hasn't been taken.  Lines
+// held by devices stay as they are.
+func (c *InterruptController) Reset() {
+	c.nmiPending = false
+	c.nmiLevel = c.level(LineNMI)
+}
+
+// Asserted returns the devices currently pulling each line low
+func (c *InterruptController) Asserted() []InterruptState {
+	var result []InterruptState
+	for _, s := range c.sources {
+		if s.asserted() {
+			result = append(result, InterruptState{s.line, s.name})
+		}
+	}
+	for state := range c.held {
+		result = append(result, state)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Line != result[j].Line {
+			return result[i].Line < result[j].Line
+		}
+		return result[i].Device < result[j].Device
+	})
+	return result
+}
+
+// Service is called between instructions.  It takes an NMI if
+// the line has gone low since the last check, otherwise an IRQ
+// if the line is low and the I flag is clear.  An IRQ also wakes
+// up WAI with interrupts disabled.  Returns the number of cycles
+// taken.
+func (c *InterruptController) Service(cpu *CPU) uint8 {
+	nmi := c.level(LineNMI)
+	if nmi && !c.nmiLevel {
+		c.nmiPending = true
+	}
+	c.nmiLevel = nmi
+
+	if c.nmiPending {
+		c.nmiPending = false
+		return cpu.NonMaskableInterrupt()
+	}
+
+	if c.level(LineIRQ) {
+		if cpu.P&FlagInterrupt == 0 {
+			return cpu.Interrupt()
+		}
+		cpu.Waiting = false
+	}
+	return 0
+}
+
+// level returns true if anything is pulling the line low
+func (c *InterruptController) level(line InterruptLine) bool {
+	for state := range c.held {
+		if state.Line == line {
+			return true
+		}
+	}
+	for _, s := range c.sources {
+		if s.line == line && s.asserted() {
+			return true
+		}
+	}
+	return false
+}
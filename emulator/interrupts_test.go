@@ -0,0 +1,168 @@
+package emulator
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Where the test vectors point, to tell which interrupt was taken
+const (
+	testIRQHandler = 0x3000
+	testNMIHandler = 0x4000
+	testProgram    = 0x0400
+)
+
+// interruptTest drives an InterruptController with two polled
+// devices on each line, "a" and "b"
+type interruptTest struct {
+	c     *InterruptController
+	cpu   *CPU
+	lines map[InterruptState]bool
+	taken []string
+}
+
+func newInterruptTest() *interruptTest {
+	result := &interruptTest{
+		c:     NewInterruptController(),
+		cpu:   newTestCPU(Variant65C02),
+		lines: make(map[InterruptState]bool),
+	}
+	for _, line := range []InterruptLine{LineIRQ, LineNMI} {
+		for _, device := range []string{"a", "b"} {
+			state := InterruptState{line, device}
+			result.c.AddSource(device, line, func() bool { return result.lines[state] })
+		}
+	}
+	result.cpu.Bus.Write16(IrqVector, testIRQHandler)
+	result.cpu.Bus.Write16(NmiVector, testNMIHandler)
+	result.ready()
+	return result
+}
+
+// ready puts the CPU back in the program with interrupts enabled
+func (it *interruptTest) ready() {
+	it.cpu.PC = testProgram
+	it.cpu.P = FlagUnused
+	it.cpu.SP = 0xFD
+}
+
+// interruptStep is something done in an interrupt test
+type interruptStep func(it *interruptTest)
+
+// low pulls a polled device's line low
+func low(line InterruptLine, device string) interruptStep {
+	return func(it *interruptTest) { it.lines[InterruptState{line, device}] = true }
+}
+
+// high lets go of a polled device's line
+func high(line InterruptLine, device string) interruptStep {
+	return func(it *interruptTest) { delete(it.lines, InterruptState{line, device}) }
+}
+
+func assert(line InterruptLine, device string) interruptStep {
+	return func(it *interruptTest) { it.c.Assert(line, device) }
+}
+
+func release(line InterruptLine, device string) interruptStep {
+	return func(it *interruptTest) { it.c.Release(line, device) }
+}
+
+// service checks the lines between instructions and records
+// which interrupt, if any, was taken
+func service(it *interruptTest) {
+	it.c.Service(it.cpu)
+	switch it.cpu.PC {
+	case testIRQHandler:
+		it.taken = append(it.taken, "IRQ")
+	case testNMIHandler:
+		it.taken = append(it.taken, "NMI")
+	default:
+		it.taken = append(it.taken, "-")
+	}
+	it.ready()
+}
+
+func maskIRQ(it *interruptTest) {
+	it.cpu.P |= FlagInterrupt
+}
+
+func resetInterrupts(it *interruptTest) {
+	it.c.Reset()
+}
+
+func TestInterruptController(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []interruptStep
+		taken []string // Result of each service step
+	}{
+		{"nothing", []interruptStep{service}, []string{"-"}},
+
+		// IRQ is level triggered and shared
+		{"IRQ", []interruptStep{low(LineIRQ, "a"), service, service}, []string{"IRQ", "IRQ"}},
+		{"IRQ released", []interruptStep{low(LineIRQ, "a"), service, high(LineIRQ, "a"), service}, []string{"IRQ", "-"}},
+		{"IRQ shared", []interruptStep{
+			low(LineIRQ, "a"), low(LineIRQ, "b"), service,
+			high(LineIRQ, "a"), service,
+			high(LineIRQ, "b"), service,
+		}, []string{"IRQ", "IRQ", "-"}},
+		{"IRQ shared with Assert", []interruptStep{
+			low(LineIRQ, "a"), assert(LineIRQ, "c"), service,
+			high(LineIRQ, "a"), service,
+			release(LineIRQ, "c"), service,
+		}, []string{"IRQ", "IRQ", "-"}},
+		{"IRQ masked", []interruptStep{maskIRQ, low(LineIRQ, "a"), service}, []string{"-"}},
+
+		// NMI is edge triggered
+		{"NMI", []interruptStep{low(LineNMI, "a"), service}, []string{"NMI"}},
+		{"NMI once per edge", []interruptStep{low(LineNMI, "a"), service, service}, []string{"NMI", "-"}},
+		{"NMI again", []interruptStep{low(LineNMI, "a"), service, high(LineNMI, "a"), service, low(LineNMI, "a"), service}, []string{"NMI", "-", "NMI"}},
+		{"NMI not masked", []interruptStep{maskIRQ, low(LineNMI, "a"), service}, []string{"NMI"}},
+		{"NMI before IRQ", []interruptStep{low(LineIRQ, "a"), low(LineNMI, "a"), service, service}, []string{"NMI", "IRQ"}},
+		{"NMI no edge while held", []interruptStep{low(LineNMI, "a"), service, low(LineNMI, "b"), service, high(LineNMI, "a"), service}, []string{"NMI", "-", "-"}},
+		{"NMI pulse", []interruptStep{assert(LineNMI, "c"), release(LineNMI, "c"), service}, []string{"NMI"}},
+		{"NMI pulse while held", []interruptStep{low(LineNMI, "a"), service, assert(LineNMI, "c"), release(LineNMI, "c"), service}, []string{"NMI", "-"}},
+
+		// Reset
+		{"Reset clears a pending NMI", []interruptStep{assert(LineNMI, "c"), release(LineNMI, "c"), resetInterrupts, service}, []string{"-"}},
+		{"Reset with NMI held", []interruptStep{low(LineNMI, "a"), resetInterrupts, service}, []string{"-"}},
+		{"Reset leaves IRQ", []interruptStep{assert(LineIRQ, "c"), resetInterrupts, service}, []string{"IRQ"}},
+		{"NMI after reset", []interruptStep{low(LineNMI, "a"), resetInterrupts, high(LineNMI, "a"), service, low(LineNMI, "a"), service}, []string{"-", "NMI"}},
+	}
+
+	for _, test := range tests {
+		it := newInterruptTest()
+		for _, step := range test.steps {
+			step(it)
+		}
+		if !reflect.DeepEqual(it.taken, test.taken) {
+			t.Errorf("%s: took %v, expected %v", test.name, it.taken, test.taken)
+		}
+	}
+}
+
+func TestInterruptWakesWAI(t *testing.T) {
+	it := newInterruptTest()
+	maskIRQ(it)
+	it.cpu.Waiting = true
+	low(LineIRQ, "a")(it)
+	it.c.Service(it.cpu)
+	if it.cpu.Waiting {
+		t.Error("Masked IRQ didn't end WAI")
+	}
+	if it.cpu.PC != testProgram {
+		t.Errorf("Masked IRQ taken, PC is $%04X", it.cpu.PC)
+	}
+}
+
+func TestInterruptsAsserted(t *testing.T) {
+	it := newInterruptTest()
+	low(LineIRQ, "b")(it)
+	low(LineIRQ, "a")(it)
+	assert(LineNMI, "c")(it)
+
+	expected := []InterruptState{{LineIRQ, "a"}, {LineIRQ, "b"}, {LineNMI, "c"}}
+	if got := it.c.Asserted(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Asserted %v, expected %v", got, expected)
+	}
+}
@@ -1,33 +1,110 @@
 package emulator
 
-// KeyboardInterface is the Memory (see i6502) component to write
-// to the screen
+// DefaultKeyBufferDepth is the number of keys the keyboard
+// holds before it starts dropping them
+const DefaultKeyBufferDepth = 16
+
+// Keyboard status register bits
+const (
+	KeyStatusAvailable byte = 0x80 // At least one key is waiting
+	KeyStatusOverflow  byte = 0x40 // Keys were dropped since the last read
+	KeyStatusCount     byte = 0x3F // Number of keys waiting, up to 63
+)
+
+// KeyboardInterface is the Memory component the keys typed
+// are read from.  Keys are queued in a FIFO and read one at
+// a time from the first address, which returns 0 when the
+// queue is empty.  If the device is two bytes long, the second
+// is a status register with the number of keys waiting.
 type KeyboardInterface struct {
-	KeyWaiting bool
-	Key        rune
+	keys     []rune
+	depth    int
+	size     uint16
+	overflow bool
+
+	// Overflows counts the keys dropped because the
+	// buffer was full
+	Overflows int
 }
 
-// NewKeyboardInterface returns a new screen interface
-func NewKeyboardInterface() *KeyboardInterface {
-	return &KeyboardInterface{}
+// NewKeyboardInterface returns a new keyboard interface
+// holding up to depth keys, with a status register if
+// size is 2
+func NewKeyboardInterface(depth int, size uint16) *KeyboardInterface {
+	return &KeyboardInterface{
+		keys:  make([]rune, 0, depth),
+		depth: depth,
+		size:  size,
+	}
 }
 
-// Size returns the constant 1
+// Size returns 1, or 2 with the status register
 func (s *KeyboardInterface) Size() uint16 {
-	return 1
+	return s.size
+}
+
+// PushKey adds a key to the end of the queue.  It returns
+// false, and the key is lost, if the queue is full.
+func (s *KeyboardInterface) PushKey(k rune) bool {
+	if len(s.keys) >= s.depth {
+		s.overflow = true
+		s.Overflows++
+		return false
+	}
+	s.keys = append(s.keys, k)
+	return true
+}
+
+// Pending returns the number of keys waiting to be read
+func (s *KeyboardInterface) Pending() int {
+	return len(s.keys)
+}
+
+// IsFull returns true if another key would be dropped
+func (s *KeyboardInterface) IsFull() bool {
+	return len(s.keys) >= s.depth
 }
 
-// ReadByte reads the memory from the screen interface
-func (s *KeyboardInterface) ReadByte(address uint16) byte {
-	var result byte = 0
-	if s.KeyWaiting {
-		result = byte(s.Key)
-		s.KeyWaiting = false
+// Read reads the next key, or the status register
+func (s *KeyboardInterface) Read(address uint16) byte {
+	if address == 1 {
+		return s.status()
 	}
+
+	if len(s.keys) == 0 {
+		return 0
+	}
+	result := byte(s.keys[0])
+	s.keys = s.keys[1:]
 	return result
 }
 
-// WriteByte writes to the screen interface memory location
-func (s *KeyboardInterface) WriteByte(address uint16, data byte) {
+// IRQ holds the IRQ line low until all the
+// keys have been read
+func (s *KeyboardInterface) IRQ() bool {
+	return len(s.keys) > 0
+}
+
+// Write writes to the keyboard interface memory location
+func (s *KeyboardInterface) Write(address uint16, data byte) {
 	// nothing
 }
+
+// status returns the status register, and clears the
+// overflow flag
+func (s *KeyboardInterface) status() byte {
+	count := len(s.keys)
+	if count > int(KeyStatusCount) {
+		count = int(KeyStatusCount)
+	}
+
+	result := byte(count)
+	if count > 0 {
+		result |= KeyStatusAvailable
+	}
+	if s.overflow {
+		result |= KeyStatusOverflow
+		s.overflow = false
+	}
+	return result
+}
@@ -0,0 +1,91 @@
+package emulator
+
+import "testing"
+
+func TestKeyboardFIFO(t *testing.T) {
+	k := NewKeyboardInterface(4, 2)
+	for _, r := range "abc" {
+		k.PushKey(r)
+	}
+	if !k.IRQ() {
+		t.Error("No IRQ with keys waiting")
+	}
+	for _, r := range "abc" {
+		if got := k.Read(0); got != byte(r) {
+			t.Errorf("Read %q, expected %q", got, r)
+		}
+	}
+	if got := k.Read(0); got != 0 {
+		t.Errorf("Read $%02X from an empty buffer", got)
+	}
+	if k.IRQ() {
+		t.Error("IRQ with every key read")
+	}
+}
+
+func TestKeyboardOverflow(t *testing.T) {
+	k := NewKeyboardInterface(2, 2)
+	for i, r := range "abcd" {
+		if ok := k.PushKey(r); ok != (i < 2) {
+			t.Errorf("PushKey(%q) returned %t", r, ok)
+		}
+	}
+	if !k.IsFull() {
+		t.Error("Not full")
+	}
+	if k.Overflows != 2 {
+		t.Errorf("Overflows is %d, expected 2", k.Overflows)
+	}
+
+	// The keys that fitted are kept, the rest dropped
+	if got := string([]byte{k.Read(0), k.Read(0), k.Read(0)}); got != "ab\x00" {
+		t.Errorf("Read %q", got)
+	}
+	if k.IsFull() {
+		t.Error("Still full after reading the keys")
+	}
+}
+
+// keyStep is something done to the keyboard in a test
+type keyStep func(k *KeyboardInterface)
+
+func TestKeyboardStatus(t *testing.T) {
+	push := func(n int) keyStep {
+		return func(k *KeyboardInterface) {
+			for i := 0; i < n; i++ {
+				k.PushKey('x')
+			}
+		}
+	}
+	read := func(address uint16) keyStep {
+		return func(k *KeyboardInterface) { k.Read(address) }
+	}
+
+	tests := []struct {
+		name   string
+		depth  int
+		steps  []keyStep
+		status byte
+	}{
+		{"empty", 4, nil, 0},
+		{"one key", 4, []keyStep{push(1)}, KeyStatusAvailable | 1},
+		{"full", 4, []keyStep{push(4)}, KeyStatusAvailable | 4},
+		{"key read", 4, []keyStep{push(2), read(0)}, KeyStatusAvailable | 1},
+		{"all read", 4, []keyStep{push(2), read(0), read(0)}, 0},
+		{"overflow", 4, []keyStep{push(5)}, KeyStatusAvailable | KeyStatusOverflow | 4},
+		{"overflow after keys read", 4, []keyStep{push(5), read(0), read(0), read(0), read(0)}, KeyStatusOverflow},
+		{"status read clears overflow", 4, []keyStep{push(5), read(1)}, KeyStatusAvailable | 4},
+		{"status read leaves keys", 4, []keyStep{push(3), read(1), read(1)}, KeyStatusAvailable | 3},
+		{"count limit", 100, []keyStep{push(70)}, KeyStatusAvailable | KeyStatusCount},
+	}
+
+	for _, test := range tests {
+		k := NewKeyboardInterface(test.depth, 2)
+		for _, step := range test.steps {
+			step(k)
+		}
+		if status := k.Read(1); status != test.status {
+			t.Errorf("%s: status is $%02X, expected $%02X", test.name, status, test.status)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Machine describes the memory map of the computer being
+// emulated: the RAM, ROM and I/O devices and where they
+// appear in the address space
+type Machine struct {
+	Name    string         `json:"name"`
+	Devices []DeviceConfig `json:"devices"`
+
+	// Relative file names in the options are relative to this,
+	// the directory the description was loaded from
+	dir string
+}
+
+// DeviceConfig is a single entry in the memory map
+type DeviceConfig struct {
+	Type    string                 `json:"type"` // One of the keys in deviceTypes
+	Name    string                 `json:"name,omitempty"`
+	Address Number                 `json:"address"`
+	Size    Number                 `json:"size,omitempty"` // Not needed for devices with a fixed size
+	Options map[string]interface{} `json:"options,omitempty"`
+}
+
+// Number is an address or size in a machine description,
+// which can be given either as a JSON number or as a string
+// such as "$8000", "0x8000" or "32768"
+type Number int
+
+// DefaultMachine returns the memory map of the original
+// Kabputer: 32k RAM, the screen and keyboard and then
+// another 31k RAM
+func DefaultMachine() *Machine {
+	return &Machine{
+		Name: "Kabputer",
+		Devices: []DeviceConfig{
+			{Type: "ram", Address: 0x0000, Size: 0x8000},
+			{Type: "screen", Address: 0x8000},
+			{Type: "keyboard", Address: 0x8001},
+			{Type: "ram", Address: 0x8400, Size: 0x7C00},
+		},
+	}
+}
+
+// LoadMachine reads a machine description from a JSON file
+func LoadMachine(filename string) (*Machine, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Machine{dir: filepath.Dir(filename)}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("Parsing %s: %s", filename, err)
+	}
+	if err := result.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	return result, nil
+}
+
+// Validate checks that every device is of a known type and
+// fits in the address space without overlapping any other
+func (m *Machine) Validate() error {
+	type span struct {
+		start, end int
+		name       string
+	}
+	spans := make([]span, 0, len(m.Devices))
+
+	for i, d := range m.Devices {
+		deviceType, ok := deviceTypes[d.Type]
+		if !ok {
+			return fmt.Errorf("Device %d has unknown type '%s'", i+1, d.Type)
+		}
+
+		size := int(d.Size)
+		if deviceType.size != 0 {
+			size = deviceType.size
+		} else if size == 0 {
+			size = deviceType.defaultSize
+		}
+		if size <= 0 || size > 0xFFFF {
+			return fmt.Errorf("%s must have a size between 1 and $FFFF", d)
+		}
+		if d.Address < 0 || int(d.Address)+size > 0x10000 {
+			return fmt.Errorf("%s doesn't fit in the address space", d)
+		}
+		spans = append(spans, span{int(d.Address), int(d.Address) + size - 1, d.String()})
+	}
+
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	for i := 1; i < len(spans); i++ {
+		if spans[i].start <= spans[i-1].end {
+			return fmt.Errorf("%s overlaps %s", spans[i].name, spans[i-1].name)
+		}
+	}
+
+	return nil
+}
+
+// String describes the device for error messages
+func (d DeviceConfig) String() string {
+	if d.Name != "" {
+		return fmt.Sprintf("%s '%s' at $%04X", d.Type, d.Name, int(d.Address))
+	}
+	return fmt.Sprintf("%s at $%04X", d.Type, int(d.Address))
+}
+
+// OptionString returns the named option as a string, or def
+// if it isn't set
+func (d DeviceConfig) OptionString(name string, def string) (string, error) {
+	value, ok := d.Options[name]
+	if !ok {
+		return def, nil
+	}
+
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("%s: option '%s' must be a string", d, name)
+}
+
+// OptionInt returns the named option as an integer, or def
+// if it isn't set.  Like addresses, it can be a string such
+// as "$10".
+func (d DeviceConfig) OptionInt(name string, def int) (int, error) {
+	value, ok := d.Options[name]
+	if !ok {
+		return def, nil
+	}
+
+	switch v := value.(type) {
+	case float64:
+		return int(v), nil
+	case string:
+		n, err := ParseNumber(v)
+		if err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("%s: option '%s' must be a number", d, name)
+}
+
+// OptionBool returns the named option as a bool, or def
+// if it isn't set
+func (d DeviceConfig) OptionBool(name string, def bool) (bool, error) {
+	value, ok := d.Options[name]
+	if !ok {
+		return def, nil
+	}
+
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("%s: option '%s' must be true or false", d, name)
+}
+
+// HasROMImage returns true if any of the ROMs is loaded
+// from a file
+func (m *Machine) HasROMImage() bool {
+	for _, d := range m.Devices {
+		if filename, _ := d.OptionString("file", ""); d.Type == "rom" && filename != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// path returns a file name from the options, relative to the
+// directory the machine description was loaded from
+func (m *Machine) path(filename string) string {
+	if filename == "" || filepath.IsAbs(filename) || m.dir == "" {
+		return filename
+	}
+	return filepath.Join(m.dir, filename)
+}
+
+// ParseNumber converts a string such as "$8000", "0x8000"
+// or "32768" into a number
+func ParseNumber(s string) (int, error) {
+	str := strings.TrimSpace(s)
+	base := 10
+	switch {
+	case strings.HasPrefix(str, "$"):
+		str = str[1:]
+		base = 16
+	case strings.HasPrefix(strings.ToLower(str), "0x"):
+		str = str[2:]
+		base = 16
+	}
+
+	result, err := strconv.ParseInt(str, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid number '%s'", s)
+	}
+	return int(result), nil
+}
+
+// UnmarshalJSON accepts either a number or a string
+func (n *Number) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		value, err := ParseNumber(s)
+		if err != nil {
+			return err
+		}
+		*n = Number(value)
+		return nil
+	}
+
+	var value int
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("Invalid number %s", string(data))
+	}
+	*n = Number(value)
+	return nil
+}
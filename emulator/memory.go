@@ -0,0 +1,108 @@
+package emulator
+
+// Memory is anything that can be attached to the
+// AddressBus and accessed by the CPU
+type Memory interface {
+	Size() uint16
+	Read(address uint16) byte
+	Write(address uint16, data byte)
+}
+
+// Ticker is implemented by devices that need to know how many
+// cycles have passed, e.g. to run timers
+type Ticker interface {
+	Tick(cycles uint8)
+}
+
+// Resetter is implemented by devices with a RES pin, which are
+// reset along with the CPU
+type Resetter interface {
+	Reset()
+}
+
+// InterruptSource is implemented by devices that can
+// pull the IRQ line low
+type InterruptSource interface {
+	IRQ() bool
+}
+
+// Loadable is memory the host can fill in directly, e.g. to
+// load a program, as opposed to the I/O devices
+type Loadable interface {
+	LoadByte(address uint16, data byte)
+}
+
+// Ram is read/write memory of any size
+type Ram struct {
+	data []byte
+}
+
+// NewRam creates a new Ram component of the given size
+func NewRam(size int) (*Ram, error) {
+	return &Ram{data: make([]byte, size)}, nil
+}
+
+// Size returns the size of the ram
+func (r *Ram) Size() uint16 {
+	return uint16(len(r.data))
+}
+
+// Read reads a byte from the ram
+func (r *Ram) Read(address uint16) byte {
+	return r.data[address]
+}
+
+// Write writes a byte to the ram
+func (r *Ram) Write(address uint16, data byte) {
+	r.data[address] = data
+}
+
+// LoadByte writes a byte to the ram on behalf of the host
+func (r *Ram) LoadByte(address uint16, data byte) {
+	r.data[address] = data
+}
+
+// Clear sets the whole ram to 0
+func (r *Ram) Clear() {
+	for i := range r.data {
+		r.data[i] = 0
+	}
+}
+
+// Rom is memory the CPU can only read, writes from the CPU are
+// ignored.  The host can still fill it in with LoadByte.
+type Rom struct {
+	data []byte
+
+	// WriteHandler, if set, is called when the CPU tries to
+	// write to the rom
+	WriteHandler func(address uint16, data byte)
+}
+
+// NewRom creates a new Rom component of the given size
+func NewRom(size int) (*Rom, error) {
+	return &Rom{data: make([]byte, size)}, nil
+}
+
+// Size returns the size of the rom
+func (r *Rom) Size() uint16 {
+	return uint16(len(r.data))
+}
+
+// Read reads a byte from the rom
+func (r *Rom) Read(address uint16) byte {
+	return r.data[address]
+}
+
+// Write leaves the rom unchanged, but lets the
+// WriteHandler know about the attempt
+func (r *Rom) Write(address uint16, data byte) {
+	if r.WriteHandler != nil {
+		r.WriteHandler(address, data)
+	}
+}
+
+// LoadByte writes a byte to the rom on behalf of the host
+func (r *Rom) LoadByte(address uint16, data byte) {
+	r.data[address] = data
+}
@@ -1,9 +1,11 @@
 package emulator
 
+import "fmt"
+
 /**************************************************
-* Based on github.com/ariejon/i6502  library
-*
-* Pulled into here
+* Originally based on the github.com/ariejan/i6502
+* library, this is now the decode table for the
+* native CPU in cpu.go
 *
 ***************************************************/
 
@@ -23,6 +25,9 @@ const (
 	zeropage
 	zeropageX
 	zeropageY
+	zeropageIndirect
+	absoluteIndexedIndirect
+	zeropageRelative
 )
 
 var addressingNames = [...]string{
@@ -40,6 +45,9 @@ var addressingNames = [...]string{
 	"zeropage",
 	"zeropageX",
 	"zeropageY",
+	"(zeropage)",
+	"(absolute,X)",
+	"zeropage,relative",
 }
 
 // OpCode table
@@ -101,6 +109,44 @@ const (
 	txa
 	txs
 	tya
+
+	// 65C02 extensions
+	bra
+	phx
+	phy
+	plx
+	ply
+	stz
+	trb
+	tsb
+	rmb
+	smb
+	bbr
+	bbs
+	wai
+	stp
+
+	// NMOS undocumented instructions
+	slo
+	rla
+	sre
+	rra
+	sax
+	lax
+	dcp
+	isc
+	anc
+	alr
+	arr
+	sbx
+	las
+	ane
+	lxa
+	sha
+	shx
+	shy
+	tas
+	jam
 )
 
 var instructionNames = [...]string{
@@ -161,6 +207,42 @@ var instructionNames = [...]string{
 	"TXA",
 	"TXS",
 	"TYA",
+
+	"BRA",
+	"PHX",
+	"PHY",
+	"PLX",
+	"PLY",
+	"STZ",
+	"TRB",
+	"TSB",
+	"RMB",
+	"SMB",
+	"BBR",
+	"BBS",
+	"WAI",
+	"STP",
+
+	"SLO",
+	"RLA",
+	"SRE",
+	"RRA",
+	"SAX",
+	"LAX",
+	"DCP",
+	"ISC",
+	"ANC",
+	"ALR",
+	"ARR",
+	"SBX",
+	"LAS",
+	"ANE",
+	"LXA",
+	"SHA",
+	"SHX",
+	"SHY",
+	"TAS",
+	"JAM",
 }
 
 // OpType is the operation type, it includes the instruction and
@@ -176,24 +258,42 @@ type OpType struct {
 	Cycles uint8 // Number of clock cycles required to complete this instruction
 }
 
-// NewOpType returns the OpType structure
-// for the specified opcode
-func NewOpType(opcode byte) OpType {
-	return opTypes[opcode]
+// NewOpType returns the OpType structure for the
+// specified opcode on the specified CPU variant
+func NewOpType(opcode byte, variant Variant) OpType {
+	result, ok := variantOpTypes[variant][opcode]
+	if !ok {
+		// Unknown opcodes are treated as a single data byte
+		result = OpType{Opcode: opcode, Size: 1}
+	}
+	return result
 }
 
 // GetInstructionName returns the name of the opcode
 // as a string
 func (o OpType) GetInstructionName() string {
+	switch o.opcodeID {
+	case rmb, smb, bbr, bbs:
+		// The bit number is part of the name, e.g. RMB3
+		return fmt.Sprintf("%s%d", instructionNames[o.opcodeID], o.bitNumber())
+	}
 	return instructionNames[o.opcodeID]
 }
 
+// bitNumber returns the bit operated on by the
+// RMB, SMB, BBR and BBS instructions
+func (o OpType) bitNumber() uint8 {
+	return (o.Opcode >> 4) & 0x07
+}
+
 // GetAddressingMode returns the name of the addressing
 // mode used for this opcode
 func (o OpType) GetAddressingMode() string {
 	return addressingNames[int(o.addressingID)]
 }
 
+// opTypes holds the documented NMOS 6502 instructions,
+// which all the variants share
 var opTypes = map[uint8]OpType{
 	0xEA: OpType{0xEA, nop, implied, 1, 2},
 
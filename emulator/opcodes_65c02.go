@@ -0,0 +1,117 @@
+package emulator
+
+// opTypes65C02 holds the WDC 65C02 additions to the
+// instruction set, along with the NMOS instructions
+// whose timing changed
+var opTypes65C02 = map[uint8]OpType{
+	// BRA
+	0x80: OpType{0x80, bra, relative, 2, 2},
+
+	// PHX / PLX / PHY / PLY
+	0xDA: OpType{0xDA, phx, implied, 1, 3},
+	0xFA: OpType{0xFA, plx, implied, 1, 4},
+	0x5A: OpType{0x5A, phy, implied, 1, 3},
+	0x7A: OpType{0x7A, ply, implied, 1, 4},
+
+	// STZ
+	0x64: OpType{0x64, stz, zeropage, 2, 3},
+	0x74: OpType{0x74, stz, zeropageX, 2, 4},
+	0x9C: OpType{0x9C, stz, absolute, 3, 4},
+	0x9E: OpType{0x9E, stz, absoluteX, 3, 5},
+
+	// TRB / TSB
+	0x14: OpType{0x14, trb, zeropage, 2, 5},
+	0x1C: OpType{0x1C, trb, absolute, 3, 6},
+	0x04: OpType{0x04, tsb, zeropage, 2, 5},
+	0x0C: OpType{0x0C, tsb, absolute, 3, 6},
+
+	// (zeropage) addressing
+	0x12: OpType{0x12, ora, zeropageIndirect, 2, 5},
+	0x32: OpType{0x32, and, zeropageIndirect, 2, 5},
+	0x52: OpType{0x52, eor, zeropageIndirect, 2, 5},
+	0x72: OpType{0x72, adc, zeropageIndirect, 2, 5},
+	0x92: OpType{0x92, sta, zeropageIndirect, 2, 5},
+	0xB2: OpType{0xB2, lda, zeropageIndirect, 2, 5},
+	0xD2: OpType{0xD2, cmp, zeropageIndirect, 2, 5},
+	0xF2: OpType{0xF2, sbc, zeropageIndirect, 2, 5},
+
+	// BIT
+	0x89: OpType{0x89, bit, immediate, 2, 2},
+	0x34: OpType{0x34, bit, zeropageX, 2, 4},
+	0x3C: OpType{0x3C, bit, absoluteX, 3, 4},
+
+	// INC A / DEC A
+	0x1A: OpType{0x1A, inc, accumulator, 1, 2},
+	0x3A: OpType{0x3A, dec, accumulator, 1, 2},
+
+	// JMP (absolute,X)
+	0x7C: OpType{0x7C, jmp, absoluteIndexedIndirect, 3, 6},
+
+	// RMB / SMB
+	0x07: OpType{0x07, rmb, zeropage, 2, 5},
+	0x17: OpType{0x17, rmb, zeropage, 2, 5},
+	0x27: OpType{0x27, rmb, zeropage, 2, 5},
+	0x37: OpType{0x37, rmb, zeropage, 2, 5},
+	0x47: OpType{0x47, rmb, zeropage, 2, 5},
+	0x57: OpType{0x57, rmb, zeropage, 2, 5},
+	0x67: OpType{0x67, rmb, zeropage, 2, 5},
+	0x77: OpType{0x77, rmb, zeropage, 2, 5},
+	0x87: OpType{0x87, smb, zeropage, 2, 5},
+	0x97: OpType{0x97, smb, zeropage, 2, 5},
+	0xA7: OpType{0xA7, smb, zeropage, 2, 5},
+	0xB7: OpType{0xB7, smb, zeropage, 2, 5},
+	0xC7: OpType{0xC7, smb, zeropage, 2, 5},
+	0xD7: OpType{0xD7, smb, zeropage, 2, 5},
+	0xE7: OpType{0xE7, smb, zeropage, 2, 5},
+	0xF7: OpType{0xF7, smb, zeropage, 2, 5},
+
+	// BBR / BBS
+	0x0F: OpType{0x0F, bbr, zeropageRelative, 3, 5},
+	0x1F: OpType{0x1F, bbr, zeropageRelative, 3, 5},
+	0x2F: OpType{0x2F, bbr, zeropageRelative, 3, 5},
+	0x3F: OpType{0x3F, bbr, zeropageRelative, 3, 5},
+	0x4F: OpType{0x4F, bbr, zeropageRelative, 3, 5},
+	0x5F: OpType{0x5F, bbr, zeropageRelative, 3, 5},
+	0x6F: OpType{0x6F, bbr, zeropageRelative, 3, 5},
+	0x7F: OpType{0x7F, bbr, zeropageRelative, 3, 5},
+	0x8F: OpType{0x8F, bbs, zeropageRelative, 3, 5},
+	0x9F: OpType{0x9F, bbs, zeropageRelative, 3, 5},
+	0xAF: OpType{0xAF, bbs, zeropageRelative, 3, 5},
+	0xBF: OpType{0xBF, bbs, zeropageRelative, 3, 5},
+	0xCF: OpType{0xCF, bbs, zeropageRelative, 3, 5},
+	0xDF: OpType{0xDF, bbs, zeropageRelative, 3, 5},
+	0xEF: OpType{0xEF, bbs, zeropageRelative, 3, 5},
+	0xFF: OpType{0xFF, bbs, zeropageRelative, 3, 5},
+
+	// WAI / STP
+	0xCB: OpType{0xCB, wai, implied, 1, 3},
+	0xDB: OpType{0xDB, stp, implied, 1, 3},
+
+	// JMP (indirect) no longer has the page wrap bug,
+	// which costs an extra cycle
+	0x6C: OpType{0x6C, jmp, indirect, 3, 6},
+
+	// Shifts and rotates only take the extra cycle
+	// for absolute,X when crossing a page
+	0x1E: OpType{0x1E, asl, absoluteX, 3, 6},
+	0x5E: OpType{0x5E, lsr, absoluteX, 3, 6},
+	0x3E: OpType{0x3E, rol, absoluteX, 3, 6},
+	0x7E: OpType{0x7E, ror, absoluteX, 3, 6},
+
+	// Unused opcodes are NOPs of various sizes.  Any not
+	// listed here are single byte, single cycle NOPs.
+	0x02: OpType{0x02, nop, immediate, 2, 2},
+	0x22: OpType{0x22, nop, immediate, 2, 2},
+	0x42: OpType{0x42, nop, immediate, 2, 2},
+	0x62: OpType{0x62, nop, immediate, 2, 2},
+	0x82: OpType{0x82, nop, immediate, 2, 2},
+	0xC2: OpType{0xC2, nop, immediate, 2, 2},
+	0xE2: OpType{0xE2, nop, immediate, 2, 2},
+	0x44: OpType{0x44, nop, zeropage, 2, 3},
+	0x54: OpType{0x54, nop, zeropageX, 2, 4},
+	0xD4: OpType{0xD4, nop, zeropageX, 2, 4},
+	0xF4: OpType{0xF4, nop, zeropageX, 2, 4},
+	0x5C: OpType{0x5C, nop, absolute, 3, 8},
+	0xDC: OpType{0xDC, nop, absolute, 3, 4},
+	0xFC: OpType{0xFC, nop, absolute, 3, 4},
+}
@@ -0,0 +1,137 @@
+package emulator
+
+// opTypesUndocumented holds the undocumented instructions of
+// the NMOS 6502.  The unstable ones (ANE, LXA, SHA, SHX, SHY
+// and TAS) follow their most commonly observed behavior.
+var opTypesUndocumented = map[uint8]OpType{
+	// SLO
+	0x07: OpType{0x07, slo, zeropage, 2, 5},
+	0x17: OpType{0x17, slo, zeropageX, 2, 6},
+	0x0F: OpType{0x0F, slo, absolute, 3, 6},
+	0x1F: OpType{0x1F, slo, absoluteX, 3, 7},
+	0x1B: OpType{0x1B, slo, absoluteY, 3, 7},
+	0x03: OpType{0x03, slo, indirectX, 2, 8},
+	0x13: OpType{0x13, slo, indirectY, 2, 8},
+
+	// RLA
+	0x27: OpType{0x27, rla, zeropage, 2, 5},
+	0x37: OpType{0x37, rla, zeropageX, 2, 6},
+	0x2F: OpType{0x2F, rla, absolute, 3, 6},
+	0x3F: OpType{0x3F, rla, absoluteX, 3, 7},
+	0x3B: OpType{0x3B, rla, absoluteY, 3, 7},
+	0x23: OpType{0x23, rla, indirectX, 2, 8},
+	0x33: OpType{0x33, rla, indirectY, 2, 8},
+
+	// SRE
+	0x47: OpType{0x47, sre, zeropage, 2, 5},
+	0x57: OpType{0x57, sre, zeropageX, 2, 6},
+	0x4F: OpType{0x4F, sre, absolute, 3, 6},
+	0x5F: OpType{0x5F, sre, absoluteX, 3, 7},
+	0x5B: OpType{0x5B, sre, absoluteY, 3, 7},
+	0x43: OpType{0x43, sre, indirectX, 2, 8},
+	0x53: OpType{0x53, sre, indirectY, 2, 8},
+
+	// RRA
+	0x67: OpType{0x67, rra, zeropage, 2, 5},
+	0x77: OpType{0x77, rra, zeropageX, 2, 6},
+	0x6F: OpType{0x6F, rra, absolute, 3, 6},
+	0x7F: OpType{0x7F, rra, absoluteX, 3, 7},
+	0x7B: OpType{0x7B, rra, absoluteY, 3, 7},
+	0x63: OpType{0x63, rra, indirectX, 2, 8},
+	0x73: OpType{0x73, rra, indirectY, 2, 8},
+
+	// SAX
+	0x87: OpType{0x87, sax, zeropage, 2, 3},
+	0x97: OpType{0x97, sax, zeropageY, 2, 4},
+	0x8F: OpType{0x8F, sax, absolute, 3, 4},
+	0x83: OpType{0x83, sax, indirectX, 2, 6},
+
+	// LAX
+	0xA7: OpType{0xA7, lax, zeropage, 2, 3},
+	0xB7: OpType{0xB7, lax, zeropageY, 2, 4},
+	0xAF: OpType{0xAF, lax, absolute, 3, 4},
+	0xBF: OpType{0xBF, lax, absoluteY, 3, 4},
+	0xA3: OpType{0xA3, lax, indirectX, 2, 6},
+	0xB3: OpType{0xB3, lax, indirectY, 2, 5},
+
+	// DCP
+	0xC7: OpType{0xC7, dcp, zeropage, 2, 5},
+	0xD7: OpType{0xD7, dcp, zeropageX, 2, 6},
+	0xCF: OpType{0xCF, dcp, absolute, 3, 6},
+	0xDF: OpType{0xDF, dcp, absoluteX, 3, 7},
+	0xDB: OpType{0xDB, dcp, absoluteY, 3, 7},
+	0xC3: OpType{0xC3, dcp, indirectX, 2, 8},
+	0xD3: OpType{0xD3, dcp, indirectY, 2, 8},
+
+	// ISC
+	0xE7: OpType{0xE7, isc, zeropage, 2, 5},
+	0xF7: OpType{0xF7, isc, zeropageX, 2, 6},
+	0xEF: OpType{0xEF, isc, absolute, 3, 6},
+	0xFF: OpType{0xFF, isc, absoluteX, 3, 7},
+	0xFB: OpType{0xFB, isc, absoluteY, 3, 7},
+	0xE3: OpType{0xE3, isc, indirectX, 2, 8},
+	0xF3: OpType{0xF3, isc, indirectY, 2, 8},
+
+	// Immediate operations
+	0x0B: OpType{0x0B, anc, immediate, 2, 2},
+	0x2B: OpType{0x2B, anc, immediate, 2, 2},
+	0x4B: OpType{0x4B, alr, immediate, 2, 2},
+	0x6B: OpType{0x6B, arr, immediate, 2, 2},
+	0xCB: OpType{0xCB, sbx, immediate, 2, 2},
+	0xEB: OpType{0xEB, sbc, immediate, 2, 2},
+	0x8B: OpType{0x8B, ane, immediate, 2, 2},
+	0xAB: OpType{0xAB, lxa, immediate, 2, 2},
+
+	// LAS
+	0xBB: OpType{0xBB, las, absoluteY, 3, 4},
+
+	// SHA / SHX / SHY / TAS
+	0x93: OpType{0x93, sha, indirectY, 2, 6},
+	0x9F: OpType{0x9F, sha, absoluteY, 3, 5},
+	0x9E: OpType{0x9E, shx, absoluteY, 3, 5},
+	0x9C: OpType{0x9C, shy, absoluteX, 3, 5},
+	0x9B: OpType{0x9B, tas, absoluteY, 3, 5},
+
+	// NOPs
+	0x1A: OpType{0x1A, nop, implied, 1, 2},
+	0x3A: OpType{0x3A, nop, implied, 1, 2},
+	0x5A: OpType{0x5A, nop, implied, 1, 2},
+	0x7A: OpType{0x7A, nop, implied, 1, 2},
+	0xDA: OpType{0xDA, nop, implied, 1, 2},
+	0xFA: OpType{0xFA, nop, implied, 1, 2},
+	0x80: OpType{0x80, nop, immediate, 2, 2},
+	0x82: OpType{0x82, nop, immediate, 2, 2},
+	0x89: OpType{0x89, nop, immediate, 2, 2},
+	0xC2: OpType{0xC2, nop, immediate, 2, 2},
+	0xE2: OpType{0xE2, nop, immediate, 2, 2},
+	0x04: OpType{0x04, nop, zeropage, 2, 3},
+	0x44: OpType{0x44, nop, zeropage, 2, 3},
+	0x64: OpType{0x64, nop, zeropage, 2, 3},
+	0x14: OpType{0x14, nop, zeropageX, 2, 4},
+	0x34: OpType{0x34, nop, zeropageX, 2, 4},
+	0x54: OpType{0x54, nop, zeropageX, 2, 4},
+	0x74: OpType{0x74, nop, zeropageX, 2, 4},
+	0xD4: OpType{0xD4, nop, zeropageX, 2, 4},
+	0xF4: OpType{0xF4, nop, zeropageX, 2, 4},
+	0x0C: OpType{0x0C, nop, absolute, 3, 4},
+	0x1C: OpType{0x1C, nop, absoluteX, 3, 4},
+	0x3C: OpType{0x3C, nop, absoluteX, 3, 4},
+	0x5C: OpType{0x5C, nop, absoluteX, 3, 4},
+	0x7C: OpType{0x7C, nop, absoluteX, 3, 4},
+	0xDC: OpType{0xDC, nop, absoluteX, 3, 4},
+	0xFC: OpType{0xFC, nop, absoluteX, 3, 4},
+
+	// JAM locks up the CPU until it is reset
+	0x02: OpType{0x02, jam, implied, 1, 2},
+	0x12: OpType{0x12, jam, implied, 1, 2},
+	0x22: OpType{0x22, jam, implied, 1, 2},
+	0x32: OpType{0x32, jam, implied, 1, 2},
+	0x42: OpType{0x42, jam, implied, 1, 2},
+	0x52: OpType{0x52, jam, implied, 1, 2},
+	0x62: OpType{0x62, jam, implied, 1, 2},
+	0x72: OpType{0x72, jam, implied, 1, 2},
+	0x92: OpType{0x92, jam, implied, 1, 2},
+	0xB2: OpType{0xB2, jam, implied, 1, 2},
+	0xD2: OpType{0xD2, jam, implied, 1, 2},
+	0xF2: OpType{0xF2, jam, implied, 1, 2},
+}
@@ -0,0 +1,11 @@
+package emulator
+
+// Registers is the register set of the 6502
+type Registers struct {
+	A  byte   // Accumulator
+	X  byte   // Index register X
+	Y  byte   // Index register Y
+	PC uint16 // Program counter
+	P  byte   // Status register
+	SP byte   // Stack pointer
+}
@@ -0,0 +1,39 @@
+package emulator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ROMWriteMode is what happens when the CPU writes to ROM
+type ROMWriteMode int
+
+// What can be done about writes to ROM
+const (
+	ROMWriteIgnore ROMWriteMode = iota // Ignore the write, like the real hardware
+	ROMWriteLog                        // Log the write and carry on
+	ROMWriteBreak                      // Log the write and pause in the debugger
+)
+
+var romWriteModeNames = [...]string{
+	"ignore",
+	"log",
+	"break",
+}
+
+// ParseROMWriteMode converts "ignore", "log" or "break"
+// into a ROMWriteMode
+func ParseROMWriteMode(s string) (ROMWriteMode, error) {
+	for i, v := range romWriteModeNames {
+		if strings.EqualFold(v, strings.TrimSpace(s)) {
+			return ROMWriteMode(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown ROM write mode '%s', must be one of %s", s, strings.Join(romWriteModeNames[:], ", "))
+}
+
+// String returns the name of the mode
+func (m ROMWriteMode) String() string {
+	return romWriteModeNames[m]
+}
@@ -1,20 +1,26 @@
 package emulator
 
-import (
-	"github.com/hculpan/go6502/screen"
-)
-
 const size = 1
 
-// ScreenInterface is the Memory (see i6502) component to write
+// Display is the device the characters written to the screen
+// interface are sent to.  The SDL window (screen.Screen), the
+// headless output (headless.Screen) and the terminal frontend
+// (terminal.Screen) all implement it.
+type Display interface {
+	ProcessRune(r rune) // Shows a character or acts on a control code
+	IsBusy() bool       // Returns true while the display can't accept a character
+	Reset()             // Clears the display, e.g. when the computer is switched on
+}
+
+// ScreenInterface is the Memory component to write
 // to the screen
 type ScreenInterface struct {
 	StartAddress uint16
-	Scr          *screen.Screen
+	Scr          Display
 }
 
 // NewScreenInterface returns a new screen interface
-func NewScreenInterface(startAddress uint16, scr *screen.Screen) (*ScreenInterface, error) {
+func NewScreenInterface(startAddress uint16, scr Display) (*ScreenInterface, error) {
 	return &ScreenInterface{StartAddress: startAddress, Scr: scr}, nil
 }
 
@@ -23,8 +29,8 @@ func (s *ScreenInterface) Size() uint16 {
 	return 1
 }
 
-// ReadByte reads the memory from the screen interface
-func (s *ScreenInterface) ReadByte(address uint16) byte {
+// Read reads the memory from the screen interface
+func (s *ScreenInterface) Read(address uint16) byte {
 	if s.Scr.IsBusy() {
 		return 1
 	} else {
@@ -32,7 +38,7 @@ func (s *ScreenInterface) ReadByte(address uint16) byte {
 	}
 }
 
-// WriteByte writes to the screen interface memory location
-func (s *ScreenInterface) WriteByte(address uint16, data byte) {
+// Write writes to the screen interface memory location
+func (s *ScreenInterface) Write(address uint16, data byte) {
 	s.Scr.ProcessRune(rune(data))
 }
@@ -0,0 +1,76 @@
+package emulator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Variant selects which processor the CPU emulates
+type Variant int
+
+// Supported CPU variants
+const (
+	VariantNMOS       Variant = iota // NMOS 6502, including the undocumented opcodes
+	VariantNMOSStrict                // NMOS 6502, undocumented opcodes trap
+	Variant65C02                     // WDC 65C02
+)
+
+var variantNames = [...]string{
+	"6502",
+	"6502-strict",
+	"65C02",
+}
+
+// variantOpTypes holds the complete decode table for each variant
+var variantOpTypes = [...]map[uint8]OpType{
+	VariantNMOS:       mergeOpTypes(opTypes, opTypesUndocumented),
+	VariantNMOSStrict: mergeOpTypes(opTypes),
+	Variant65C02:      fillOpTypes(mergeOpTypes(opTypes, opTypes65C02), OpType{0, nop, implied, 1, 1}),
+}
+
+// ParseVariant converts a name such as "6502", "6502-strict"
+// or "65C02" into a Variant
+func ParseVariant(s string) (Variant, error) {
+	for i, v := range variantNames {
+		if strings.EqualFold(v, strings.TrimSpace(s)) {
+			return Variant(i), nil
+		}
+	}
+
+	return 0, fmt.Errorf("Unknown CPU variant '%s', must be one of %s", s, strings.Join(variantNames[:], ", "))
+}
+
+// String returns the name of the variant
+func (v Variant) String() string {
+	return variantNames[v]
+}
+
+// IsCMOS returns true for the 65C02, which fixes a number
+// of the NMOS quirks
+func (v Variant) IsCMOS() bool {
+	return v == Variant65C02
+}
+
+// mergeOpTypes combines the tables, later tables overriding
+// entries in earlier ones
+func mergeOpTypes(tables ...map[uint8]OpType) map[uint8]OpType {
+	result := make(map[uint8]OpType)
+	for _, table := range tables {
+		for k, v := range table {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// fillOpTypes uses the template for every opcode missing
+// from the table
+func fillOpTypes(table map[uint8]OpType, template OpType) map[uint8]OpType {
+	for i := 0; i < 256; i++ {
+		if _, ok := table[uint8(i)]; !ok {
+			template.Opcode = uint8(i)
+			table[uint8(i)] = template
+		}
+	}
+	return table
+}
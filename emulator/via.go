@@ -0,0 +1,574 @@
+package emulator
+
+// VIA registers, offsets from the base address
+const (
+	viaORB  = 0x0 // Output/input register B
+	viaORA  = 0x1 // Output/input register A, with handshake
+	viaDDRB = 0x2 // Data direction register B
+	viaDDRA = 0x3 // Data direction register A
+	viaT1CL = 0x4 // Timer 1 counter low
+	viaT1CH = 0x5 // Timer 1 counter high
+	viaT1LL = 0x6 // Timer 1 latch low
+	viaT1LH = 0x7 // Timer 1 latch high
+	viaT2CL = 0x8 // Timer 2 counter low
+	viaT2CH = 0x9 // Timer 2 counter high
+	viaSR   = 0xA // Shift register
+	viaACR  = 0xB // Auxiliary control register
+	viaPCR  = 0xC // Peripheral control register
+	viaIFR  = 0xD // Interrupt flag register
+	viaIER  = 0xE // Interrupt enable register
+	viaORAN = 0xF // Output/input register A, no handshake
+)
+
+// VIA interrupt flags, as found in the IFR and IER
+const (
+	VIAInterruptCA2 byte = 1 << iota
+	VIAInterruptCA1
+	VIAInterruptSR
+	VIAInterruptCB2
+	VIAInterruptCB1
+	VIAInterruptT2
+	VIAInterruptT1
+	VIAInterruptAny
+)
+
+// Shift register modes, from ACR bits 2-4
+const (
+	viaShiftDisabled = iota
+	viaShiftInT2
+	viaShiftInClock
+	viaShiftInCB1
+	viaShiftOutFreeT2
+	viaShiftOutT2
+	viaShiftOutClock
+	viaShiftOutCB1
+)
+
+// VIA emulates the MOS 6522 Versatile Interface Adapter: two 8-bit
+// ports with data direction registers, two 16-bit timers, a shift
+// register and the CA1/CA2/CB1/CB2 control lines.
+//
+// Other devices hook into the pins by setting the callbacks, which
+// are called when the VIA changes its outputs, and by calling the
+// Set methods to drive its inputs.  Both happen on the emulator
+// goroutine, so code outside a device should go through
+// Emulator.Synchronize.
+type VIA struct {
+	// PortAOutput and PortBOutput are called with the state of the
+	// pins when the output register or data direction changes
+	PortAOutput func(value byte)
+	PortBOutput func(value byte)
+
+	// CA2Output and CB2Output are called when CA2 or CB2 are
+	// outputs and change level, including the shift register
+	// sending a bit on CB2
+	CA2Output func(level bool)
+	CB2Output func(level bool)
+
+	// CB1Output is called with each clock pulse when the shift
+	// register is driving CB1
+	CB1Output func(level bool)
+
+	ora, orb   byte
+	ddra, ddrb byte
+	ina, inb   byte // Levels driven onto the pins from outside
+	latchA     byte // IRA/IRB captured on CA1/CB1 when latching is on
+	latchB     byte
+
+	ca1, ca2, cb1, cb2 bool // Input levels
+
+	t1Counter int
+	t1Latch   uint16
+	t1Armed   bool
+	pb7       bool // Timer 1 output on PB7
+
+	t2Counter int
+	t2LatchLo byte
+	t2Armed   bool
+
+	sr         byte
+	srCount    int // Bits shifted since the SR was last accessed
+	srCycles   int // Cycles until the next shift
+	srCB2Level bool
+
+	acr, pcr byte
+	ifr, ier byte
+}
+
+// NewVIA creates a VIA in its reset state
+func NewVIA() *VIA {
+	result := &VIA{}
+	result.Reset()
+	return result
+}
+
+// Reset emulates the RES pin, which clears all registers
+// except the timers and shift register.  The timers keep
+// counting but can't interrupt, and shifting stops.
+func (v *VIA) Reset() {
+	v.ora, v.orb = 0, 0
+	v.ddra, v.ddrb = 0, 0
+	v.acr, v.pcr = 0, 0
+	v.ifr, v.ier = 0, 0
+	v.t1Armed, v.t2Armed = false, false
+	v.pb7 = true
+	v.ina, v.inb = 0xFF, 0xFF
+	v.ca1, v.ca2, v.cb1, v.cb2 = true, true, true, true
+	v.srCount, v.srCycles = 0, 0
+	v.portAChanged()
+	v.portBChanged()
+}
+
+// Size returns the constant 16
+func (v *VIA) Size() uint16 {
+	return 16
+}
+
+// IRQ returns true while the VIA is pulling its IRQ line low
+func (v *VIA) IRQ() bool {
+	return v.ifr&v.ier&0x7F != 0
+}
+
+// Read reads one of the VIA's registers
+func (v *VIA) Read(address uint16) byte {
+	switch address & 0x0F {
+	case viaORB:
+		v.clearPortInterrupts(VIAInterruptCB1, VIAInterruptCB2, v.pcr>>5)
+		return v.readPortB()
+	case viaORA:
+		v.clearPortInterrupts(VIAInterruptCA1, VIAInterruptCA2, v.pcr>>1)
+		v.handshakeA()
+		return v.readPortA()
+	case viaORAN:
+		return v.readPortA()
+	case viaDDRB:
+		return v.ddrb
+	case viaDDRA:
+		return v.ddra
+	case viaT1CL:
+		v.clearInterrupt(VIAInterruptT1)
+		return byte(v.t1Counter)
+	case viaT1CH:
+		return byte(v.t1Counter >> 8)
+	case viaT1LL:
+		return byte(v.t1Latch)
+	case viaT1LH:
+		return byte(v.t1Latch >> 8)
+	case viaT2CL:
+		v.clearInterrupt(VIAInterruptT2)
+		return byte(v.t2Counter)
+	case viaT2CH:
+		return byte(v.t2Counter >> 8)
+	case viaSR:
+		v.clearInterrupt(VIAInterruptSR)
+		v.restartShift()
+		return v.sr
+	case viaACR:
+		return v.acr
+	case viaPCR:
+		return v.pcr
+	case viaIFR:
+		result := v.ifr & 0x7F
+		if v.IRQ() {
+			result |= VIAInterruptAny
+		}
+		return result
+	default: // viaIER
+		return v.ier | 0x80
+	}
+}
+
+// Write writes to one of the VIA's registers
+func (v *VIA) Write(address uint16, data byte) {
+	switch address & 0x0F {
+	case viaORB:
+		v.clearPortInterrupts(VIAInterruptCB1, VIAInterruptCB2, v.pcr>>5)
+		v.orb = data
+		v.handshakeB()
+		v.portBChanged()
+	case viaORA:
+		v.clearPortInterrupts(VIAInterruptCA1, VIAInterruptCA2, v.pcr>>1)
+		v.ora = data
+		v.handshakeA()
+		v.portAChanged()
+	case viaORAN:
+		v.ora = data
+		v.portAChanged()
+	case viaDDRB:
+		v.ddrb = data
+		v.portBChanged()
+	case viaDDRA:
+		v.ddra = data
+		v.portAChanged()
+	case viaT1CL, viaT1LL:
+		v.t1Latch = (v.t1Latch & 0xFF00) | uint16(data)
+	case viaT1CH:
+		v.t1Latch = (v.t1Latch & 0x00FF) | uint16(data)<<8
+		v.t1Counter = int(v.t1Latch)
+		v.t1Armed = true
+		v.clearInterrupt(VIAInterruptT1)
+		if v.acr&0x80 != 0 {
+			v.pb7 = false
+			v.portBChanged()
+		}
+	case viaT1LH:
+		v.t1Latch = (v.t1Latch & 0x00FF) | uint16(data)<<8
+		v.clearInterrupt(VIAInterruptT1)
+	case viaT2CL:
+		v.t2LatchLo = data
+	case viaT2CH:
+		v.t2Counter = int(data)<<8 | int(v.t2LatchLo)
+		v.t2Armed = true
+		v.clearInterrupt(VIAInterruptT2)
+	case viaSR:
+		v.sr = data
+		v.clearInterrupt(VIAInterruptSR)
+		v.restartShift()
+	case viaACR:
+		v.acr = data
+		v.restartShift()
+		v.portBChanged()
+	case viaPCR:
+		v.pcr = data
+		v.updateControlOutputs()
+	case viaIFR:
+		// Writing a 1 clears the flag
+		v.ifr &^= data & 0x7F
+	default: // viaIER
+		if data&0x80 != 0 {
+			v.ier |= data & 0x7F
+		} else {
+			v.ier &^= data & 0x7F
+		}
+	}
+}
+
+// Tick runs the timers and shift register
+func (v *VIA) Tick(cycles uint8) {
+	for i := 0; i < int(cycles); i++ {
+		v.tickT1()
+		v.tickT2()
+		v.tickShift()
+	}
+}
+
+// SetPortA sets the levels other devices drive onto the
+// port A pins.  Only the pins set as inputs are affected.
+func (v *VIA) SetPortA(value byte) {
+	v.ina = value
+}
+
+// SetPortB sets the levels other devices drive onto the
+// port B pins.  Only the pins set as inputs are affected.
+// PB6 is counted by timer 2 in pulse counting mode.
+func (v *VIA) SetPortB(value byte) {
+	if v.acr&0x20 != 0 && v.inb&0x40 != 0 && value&0x40 == 0 {
+		v.countT2()
+	}
+	v.inb = value
+}
+
+// SetCA1 sets the level of the CA1 input
+func (v *VIA) SetCA1(level bool) {
+	if v.activeEdge(v.ca1, level, v.pcr&0x01 != 0) {
+		v.setInterrupt(VIAInterruptCA1)
+		v.latchA = v.pinsA()
+		if v.pcr&0x0E == 0x08 {
+			// Handshake mode, the data was taken
+			v.setCA2(true)
+		}
+	}
+	v.ca1 = level
+}
+
+// SetCA2 sets the level of the CA2 input, ignored when
+// CA2 is an output
+func (v *VIA) SetCA2(level bool) {
+	if v.pcr&0x08 == 0 && v.activeEdge(v.ca2, level, v.pcr&0x04 != 0) {
+		v.setInterrupt(VIAInterruptCA2)
+	}
+	v.ca2 = level
+}
+
+// SetCB1 sets the level of the CB1 input, which also
+// clocks the shift register in the external clock modes
+func (v *VIA) SetCB1(level bool) {
+	if v.activeEdge(v.cb1, level, v.pcr&0x10 != 0) {
+		v.setInterrupt(VIAInterruptCB1)
+		v.latchB = v.pinsB()
+		if v.pcr&0xE0 == 0x80 {
+			v.setCB2(true)
+		}
+	}
+	if !v.cb1 && level {
+		if mode := v.shiftMode(); mode == viaShiftInCB1 || mode == viaShiftOutCB1 {
+			v.shift()
+		}
+	}
+	v.cb1 = level
+}
+
+// SetCB2 sets the level of the CB2 input, which is also
+// the data shifted in by the shift register
+func (v *VIA) SetCB2(level bool) {
+	if v.pcr&0x80 == 0 && v.activeEdge(v.cb2, level, v.pcr&0x40 != 0) {
+		v.setInterrupt(VIAInterruptCB2)
+	}
+	v.cb2 = level
+}
+
+// PortA returns the levels of the port A pins
+func (v *VIA) PortA() byte {
+	return v.pinsA()
+}
+
+// PortB returns the levels of the port B pins
+func (v *VIA) PortB() byte {
+	return v.pinsB()
+}
+
+func (v *VIA) pinsA() byte {
+	return (v.ora & v.ddra) | (v.ina &^ v.ddra)
+}
+
+func (v *VIA) pinsB() byte {
+	result := (v.orb & v.ddrb) | (v.inb &^ v.ddrb)
+	if v.acr&0x80 != 0 {
+		// Timer 1 drives PB7
+		result &^= 0x80
+		if v.pb7 {
+			result |= 0x80
+		}
+	}
+	return result
+}
+
+func (v *VIA) readPortA() byte {
+	if v.acr&0x01 != 0 {
+		return v.latchA
+	}
+	return v.pinsA()
+}
+
+// readPortB returns the output register for the output pins,
+// rather than the levels on them
+func (v *VIA) readPortB() byte {
+	pins := v.pinsB()
+	if v.acr&0x02 != 0 {
+		pins = v.latchB
+	}
+	return (v.orb & v.ddrb) | (pins &^ v.ddrb)
+}
+
+func (v *VIA) portAChanged() {
+	if v.PortAOutput != nil {
+		v.PortAOutput(v.pinsA())
+	}
+}
+
+func (v *VIA) portBChanged() {
+	if v.PortBOutput != nil {
+		v.PortBOutput(v.pinsB())
+	}
+}
+
+// activeEdge returns true if going from old to level is the
+// edge selected in the PCR
+func (v *VIA) activeEdge(old, level, positive bool) bool {
+	if positive {
+		return !old && level
+	}
+	return old && !level
+}
+
+func (v *VIA) setInterrupt(flag byte) {
+	v.ifr |= flag
+}
+
+func (v *VIA) clearInterrupt(flag byte) {
+	v.ifr &^= flag
+}
+
+// clearPortInterrupts clears the flags on reading or writing
+// a port.  The second control line's flag is left alone when
+// it is set up as an independent interrupt input.
+func (v *VIA) clearPortInterrupts(c1, c2 byte, control byte) {
+	v.clearInterrupt(c1)
+	if control&0x05 != 0x01 {
+		v.clearInterrupt(c2)
+	}
+}
+
+// handshakeA drops CA2 in the handshake and pulse output modes
+func (v *VIA) handshakeA() {
+	switch v.pcr & 0x0E {
+	case 0x08:
+		v.setCA2(false)
+	case 0x0A:
+		v.setCA2(false)
+		v.setCA2(true)
+	}
+}
+
+// handshakeB drops CB2 in the handshake and pulse output modes,
+// which only apply to writes
+func (v *VIA) handshakeB() {
+	switch v.pcr & 0xE0 {
+	case 0x80:
+		v.setCB2(false)
+	case 0xA0:
+		v.setCB2(false)
+		v.setCB2(true)
+	}
+}
+
+// updateControlOutputs applies the manual CA2 and CB2 modes
+func (v *VIA) updateControlOutputs() {
+	switch v.pcr & 0x0E {
+	case 0x0C:
+		v.setCA2(false)
+	case 0x0E:
+		v.setCA2(true)
+	}
+	switch v.pcr & 0xE0 {
+	case 0xC0:
+		v.setCB2(false)
+	case 0xE0:
+		v.setCB2(true)
+	}
+}
+
+func (v *VIA) setCA2(level bool) {
+	if v.ca2 != level && v.CA2Output != nil {
+		v.CA2Output(level)
+	}
+	v.ca2 = level
+}
+
+func (v *VIA) setCB2(level bool) {
+	if v.cb2 != level && v.CB2Output != nil {
+		v.CB2Output(level)
+	}
+	v.cb2 = level
+}
+
+// tickT1 counts timer 1 down.  In one-shot mode it interrupts
+// once when it passes 0, in free-running mode it reloads from
+// the latch and interrupts every time.
+func (v *VIA) tickT1() {
+	v.t1Counter--
+	if v.t1Counter >= -1 {
+		return
+	}
+
+	freeRun := v.acr&0x40 != 0
+	if v.t1Armed || freeRun {
+		v.setInterrupt(VIAInterruptT1)
+		if v.acr&0x80 != 0 {
+			if freeRun {
+				v.pb7 = !v.pb7
+			} else {
+				v.pb7 = true
+			}
+			v.portBChanged()
+		}
+	}
+	v.t1Armed = freeRun
+
+	if freeRun {
+		v.t1Counter = int(v.t1Latch)
+	} else {
+		v.t1Counter = 0xFFFF
+	}
+}
+
+// tickT2 counts timer 2 down, unless it is counting pulses
+// on PB6.  It is always one-shot.
+func (v *VIA) tickT2() {
+	if v.acr&0x20 == 0 {
+		v.countT2()
+	}
+}
+
+func (v *VIA) countT2() {
+	v.t2Counter--
+	if v.t2Counter >= 0 {
+		return
+	}
+
+	if v.t2Armed {
+		v.setInterrupt(VIAInterruptT2)
+		v.t2Armed = false
+	}
+	v.t2Counter = 0xFFFF
+}
+
+func (v *VIA) shiftMode() int {
+	return int(v.acr>>2) & 0x07
+}
+
+// restartShift starts shifting another 8 bits, after the
+// SR has been read or written, or the mode has changed
+func (v *VIA) restartShift() {
+	v.srCount = 0
+	v.srCycles = v.shiftPeriod()
+}
+
+// shiftPeriod returns the number of cycles between bits, or 0
+// when the shift register isn't driven by the system clock
+func (v *VIA) shiftPeriod() int {
+	switch v.shiftMode() {
+	case viaShiftInT2, viaShiftOutFreeT2, viaShiftOutT2:
+		// Timer 2's low latch sets the rate, with a bit every
+		// two of its time outs
+		return 2 * (int(v.t2LatchLo) + 2)
+	case viaShiftInClock, viaShiftOutClock:
+		return 2
+	}
+	return 0
+}
+
+func (v *VIA) tickShift() {
+	if v.srCycles == 0 {
+		return
+	}
+
+	v.srCycles--
+	if v.srCycles == 0 {
+		v.shift()
+		if v.srCount < 8 || v.shiftMode() == viaShiftOutFreeT2 {
+			v.srCycles = v.shiftPeriod()
+		}
+	}
+}
+
+// shift moves one bit in from or out to CB2.  After 8 bits the
+// SR interrupt is set, except in free-running mode which keeps
+// going round without it.
+func (v *VIA) shift() {
+	mode := v.shiftMode()
+	if mode == viaShiftDisabled || (v.srCount >= 8 && mode != viaShiftOutFreeT2) {
+		return
+	}
+
+	if mode >= viaShiftOutFreeT2 {
+		bit := v.sr&0x80 != 0
+		v.sr = v.sr<<1 | v.sr>>7
+		v.setCB2(bit)
+	} else {
+		v.sr <<= 1
+		if v.cb2 {
+			v.sr |= 0x01
+		}
+	}
+
+	if mode != viaShiftInCB1 && mode != viaShiftOutCB1 && v.CB1Output != nil {
+		v.CB1Output(false)
+		v.CB1Output(true)
+	}
+
+	v.srCount++
+	if v.srCount == 8 && mode != viaShiftOutFreeT2 {
+		v.setInterrupt(VIAInterruptSR)
+	}
+}
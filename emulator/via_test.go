@@ -0,0 +1,167 @@
+package emulator
+
+import "testing"
+
+// viaStep is something done to the VIA in a test
+type viaStep func(v *VIA)
+
+func viaWrite(reg uint16, data byte) viaStep {
+	return func(v *VIA) { v.Write(reg, data) }
+}
+
+func viaRead(reg uint16) viaStep {
+	return func(v *VIA) { v.Read(reg) }
+}
+
+func viaTick(cycles int) viaStep {
+	return func(v *VIA) {
+		for ; cycles > 255; cycles -= 255 {
+			v.Tick(255)
+		}
+		v.Tick(uint8(cycles))
+	}
+}
+
+func viaReset(v *VIA) {
+	v.Reset()
+}
+
+// startT1 loads timer 1 with 10, so it times out after 12 cycles
+var startT1 = []viaStep{viaWrite(viaT1CL, 10), viaWrite(viaT1CH, 0)}
+
+func steps(groups ...[]viaStep) []viaStep {
+	var result []viaStep
+	for _, g := range groups {
+		result = append(result, g...)
+	}
+	return result
+}
+
+func TestVIAInterrupts(t *testing.T) {
+	enableT1 := viaWrite(viaIER, 0x80|VIAInterruptT1)
+	shiftOut := []viaStep{viaWrite(viaACR, viaShiftOutClock<<2), viaWrite(viaSR, 0xA5)}
+
+	tests := []struct {
+		name  string
+		steps []viaStep
+		ifr   byte // IFR afterwards, bit 7 set if IRQ should be asserted
+	}{
+		// Timer 1 one-shot
+		{"T1 before time out", steps(startT1, []viaStep{viaTick(11)}), 0},
+		{"T1 time out", steps(startT1, []viaStep{viaTick(12)}), VIAInterruptT1},
+		{"T1 read clears", steps(startT1, []viaStep{viaTick(12), viaRead(viaT1CL)}), 0},
+		{"T1 write clears", steps(startT1, []viaStep{viaTick(12), viaWrite(viaT1CH, 0)}), 0},
+		{"T1 one-shot only once", steps(startT1, []viaStep{viaTick(12), viaRead(viaT1CL), viaTick(0x20000)}), 0},
+
+		// Timer 1 free-running
+		{"T1 free-run before reload", steps([]viaStep{viaWrite(viaACR, 0x40)}, startT1, []viaStep{viaTick(12), viaRead(viaT1CL), viaTick(11)}), 0},
+		{"T1 free-run reload", steps([]viaStep{viaWrite(viaACR, 0x40)}, startT1, []viaStep{viaTick(12), viaRead(viaT1CL), viaTick(12)}), VIAInterruptT1},
+
+		// Timer 2 is always one-shot
+		{"T2 time out", []viaStep{viaWrite(viaT2CL, 10), viaWrite(viaT2CH, 0), viaTick(11)}, VIAInterruptT2},
+		{"T2 before time out", []viaStep{viaWrite(viaT2CL, 10), viaWrite(viaT2CH, 0), viaTick(10)}, 0},
+		{"T2 one-shot only once", []viaStep{viaWrite(viaT2CL, 10), viaWrite(viaT2CH, 0), viaTick(11), viaRead(viaT2CL), viaTick(0x20000)}, 0},
+
+		// IFR and IER
+		{"IER enables IRQ", steps(startT1, []viaStep{enableT1, viaTick(12)}), 0x80 | VIAInterruptT1},
+		{"IER disables IRQ", steps(startT1, []viaStep{enableT1, viaWrite(viaIER, VIAInterruptT1), viaTick(12)}), VIAInterruptT1},
+		{"IRQ only for enabled flags", steps(startT1, []viaStep{viaWrite(viaIER, 0x80|VIAInterruptT2), viaTick(12)}), VIAInterruptT1},
+		{"IFR write clears", steps(startT1, []viaStep{enableT1, viaTick(12), viaWrite(viaIFR, VIAInterruptT1)}), 0},
+		{"IFR write leaves others", steps(startT1, []viaStep{enableT1, viaTick(12), viaWrite(viaIFR, VIAInterruptT2)}), 0x80 | VIAInterruptT1},
+		{"CA1 negative edge", []viaStep{func(v *VIA) { v.SetCA1(false) }}, VIAInterruptCA1},
+		{"CA1 positive edge ignored", []viaStep{func(v *VIA) { v.SetCA1(false); v.SetCA1(true) }, viaRead(viaORA)}, 0},
+		{"ORA read clears CA1", []viaStep{func(v *VIA) { v.SetCA1(false) }, viaRead(viaORA)}, 0},
+		{"ORAN read leaves CA1", []viaStep{func(v *VIA) { v.SetCA1(false) }, viaRead(viaORAN)}, VIAInterruptCA1},
+
+		// Shift register
+		{"SR before 8 bits", steps(shiftOut, []viaStep{viaTick(15)}), 0},
+		{"SR after 8 bits", steps(shiftOut, []viaStep{viaTick(16)}), VIAInterruptSR},
+		{"SR read clears", steps(shiftOut, []viaStep{viaTick(16), viaRead(viaSR)}), 0},
+		{"SR disabled", []viaStep{viaWrite(viaSR, 0xA5), viaTick(100)}, 0},
+		{"SR IRQ", steps(shiftOut, []viaStep{viaWrite(viaIER, 0x80|VIAInterruptSR), viaTick(16)}), 0x80 | VIAInterruptSR},
+
+		// Reset
+		{"Reset clears IFR and IER", steps(startT1, []viaStep{enableT1, viaTick(12), viaReset}), 0},
+		{"Reset disarms T1", steps(startT1, []viaStep{viaReset, viaTick(20)}), 0},
+		{"Reset stops shifting", steps(shiftOut, []viaStep{viaTick(8), viaReset, viaTick(100)}), 0},
+	}
+
+	for _, test := range tests {
+		v := NewVIA()
+		for _, step := range test.steps {
+			step(v)
+		}
+		if ifr := v.Read(viaIFR); ifr != test.ifr {
+			t.Errorf("%s: IFR is $%02X, expected $%02X", test.name, ifr, test.ifr)
+		}
+		if irq := v.IRQ(); irq != (test.ifr&0x80 != 0) {
+			t.Errorf("%s: IRQ is %t", test.name, irq)
+		}
+	}
+}
+
+func TestVIAShiftOut(t *testing.T) {
+	v := NewVIA()
+	v.Write(viaACR, viaShiftOutClock<<2)
+	v.Write(viaSR, 0xA5)
+
+	var bits byte
+	for i := 0; i < 8; i++ {
+		v.Tick(2)
+		bits <<= 1
+		if v.cb2 {
+			bits |= 1
+		}
+	}
+	if bits != 0xA5 {
+		t.Errorf("Shifted out $%02X, expected $A5", bits)
+	}
+}
+
+func TestVIAShiftIn(t *testing.T) {
+	v := NewVIA()
+	v.Write(viaACR, viaShiftInCB1<<2)
+	v.Write(viaSR, 0)
+
+	for i := 7; i >= 0; i-- {
+		v.SetCB2(0x5A&(1<<uint(i)) != 0)
+		v.SetCB1(false)
+		v.SetCB1(true)
+	}
+	if v.Read(viaIFR)&VIAInterruptSR == 0 {
+		t.Error("No SR interrupt after 8 bits")
+	}
+	if sr := v.Read(viaSR); sr != 0x5A {
+		t.Errorf("Shifted in $%02X, expected $5A", sr)
+	}
+}
+
+// TestEmulatorResetsVIA checks that resetting the computer
+// resets the devices as well as the CPU
+func TestEmulatorResetsVIA(t *testing.T) {
+	machine := &Machine{
+		Devices: []DeviceConfig{
+			{Type: "ram", Address: 0x0000, Size: 0x8000},
+			{Type: "via", Address: 0x8000, Name: "via"},
+		},
+	}
+	em, err := NewEmulatorFromMachine(nil, VariantNMOS, machine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	device, _ := em.Device("via")
+	v := device.(*VIA)
+
+	v.Write(viaIER, 0x80|VIAInterruptT1)
+	v.Write(viaT1CL, 10)
+	v.Write(viaT1CH, 0)
+	v.Tick(12)
+	if !v.IRQ() {
+		t.Fatal("Timer 1 didn't interrupt")
+	}
+
+	em.Reset()
+	if v.IRQ() || v.Read(viaIFR) != 0 || v.Read(viaIER) != 0x80 {
+		t.Error("VIA not reset")
+	}
+}
@@ -3,8 +3,6 @@ module github.com/hculpan/go6502
 go 1.15
 
 require (
-	github.com/ariejan/i6502 v0.0.0-20150813140357-5382c807c730
 	github.com/sqweek/dialog v0.0.0-20200911184034-8a3d98e8211d
-	github.com/stretchr/testify v1.7.0 // indirect
 	github.com/veandco/go-sdl2 v0.4.5
 )
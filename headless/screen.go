@@ -0,0 +1,104 @@
+package headless
+
+import (
+	"bytes"
+	"io"
+	"sync"
+
+	"github.com/hculpan/go6502/ascii"
+	"github.com/hculpan/go6502/emulator"
+)
+
+// Screen is a display for running without a window.  The
+// characters written to the screen interface are sent to a
+// writer as plain text, with the escape sequences that move
+// the cursor around removed.
+type Screen struct {
+	mu     sync.Mutex
+	out    io.Writer
+	buffer *bytes.Buffer
+
+	escapeMode     bool
+	escapeSequence []byte
+}
+
+var _ emulator.Display = (*Screen)(nil)
+
+// NewScreen creates a screen that writes to out, e.g. os.Stdout
+func NewScreen(out io.Writer) *Screen {
+	return &Screen{out: out}
+}
+
+// NewBufferScreen creates a screen that keeps its output in
+// memory, to be retrieved with String
+func NewBufferScreen() *Screen {
+	buffer := &bytes.Buffer{}
+	return &Screen{out: buffer, buffer: buffer}
+}
+
+// ProcessRune writes the character to the output
+func (s *Screen) ProcessRune(r rune) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.escapeMode {
+		s.processEscape(r)
+		return
+	}
+
+	switch {
+	case r == ascii.Backspace:
+		s.write("\b \b")
+	case r == ascii.Escape:
+		s.escapeMode = true
+		s.escapeSequence = s.escapeSequence[:0]
+	case r == ascii.Enter:
+		s.write("\n")
+	case r < 32 || r > 126:
+		// Nothing
+	default:
+		s.write(string(r))
+	}
+}
+
+// IsBusy always returns false, the output never
+// has to catch up
+func (s *Screen) IsBusy() bool {
+	return false
+}
+
+// Reset clears the in-memory buffer, there is nothing
+// to do when writing to a stream
+func (s *Screen) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.escapeMode = false
+	if s.buffer != nil {
+		s.buffer.Reset()
+	}
+}
+
+// String returns the text written so far to a buffer screen
+func (s *Screen) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.buffer == nil {
+		return ""
+	}
+	return s.buffer.String()
+}
+
+// processEscape swallows an escape sequence such as "[2J" or
+// "[10;5H", which ends with the first letter after the '['
+func (s *Screen) processEscape(r rune) {
+	s.escapeSequence = append(s.escapeSequence, byte(r))
+	isLetter := (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z')
+	if (len(s.escapeSequence) > 1 && isLetter) || s.escapeSequence[0] != '[' || len(s.escapeSequence) > 10 {
+		s.escapeMode = false
+	}
+}
+
+func (s *Screen) write(str string) {
+	// Nowhere to report a failed write, so just carry on
+	io.WriteString(s.out, str)
+}
@@ -0,0 +1,45 @@
+package keyboard
+
+import (
+	"github.com/hculpan/go6502/ascii"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// escapeSequences are sent for the keys that don't have a
+// character, following what a VT100 or xterm sends.  Each
+// is preceded by Escape.  The function keys used by the
+// emulator itself never get this far.
+var escapeSequences = map[rune]string{
+	sdl.K_UP:       "[A",
+	sdl.K_DOWN:     "[B",
+	sdl.K_RIGHT:    "[C",
+	sdl.K_LEFT:     "[D",
+	sdl.K_HOME:     "[H",
+	sdl.K_END:      "[F",
+	sdl.K_INSERT:   "[2~",
+	sdl.K_DELETE:   "[3~",
+	sdl.K_PAGEUP:   "[5~",
+	sdl.K_PAGEDOWN: "[6~",
+	sdl.K_F1:       "OP",
+	sdl.K_F2:       "OQ",
+	sdl.K_F3:       "OR",
+	sdl.K_F4:       "OS",
+	sdl.K_F5:       "[15~",
+	sdl.K_F6:       "[17~",
+	sdl.K_F7:       "[18~",
+	sdl.K_F8:       "[19~",
+	sdl.K_F9:       "[20~",
+	sdl.K_F10:      "[21~",
+	sdl.K_F11:      "[23~",
+	sdl.K_F12:      "[24~",
+}
+
+// escapeSequence returns the keys to send for r, or nil
+// if it doesn't have an escape sequence
+func escapeSequence(r rune) []rune {
+	seq, ok := escapeSequences[r]
+	if !ok {
+		return nil
+	}
+	return append([]rune{ascii.Escape}, []rune(seq)...)
+}
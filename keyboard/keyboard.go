@@ -1,8 +1,9 @@
 package keyboard
 
 import (
-	"strings"
+	"fmt"
 
+	"github.com/hculpan/go6502/ascii"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
@@ -10,99 +11,194 @@ import (
 const (
 	LeftShift  = 1073742049
 	RightShift = 1073742053
+	LeftCtrl   = 1073742048
+	RightCtrl  = 1073742052
+	LeftAlt    = 1073742050
+	RightAlt   = 1073742054
 	CapsLock   = 1073741881
-	Enter      = 13
-	Backspace  = 8
-	Escape     = 27
 )
 
 // Keyboard represents a physical keyboard
 type Keyboard struct {
 	capsLock bool
 	shiftOn  bool
+	ctrlOn   bool
+	altOn    bool
+	altGrOn  bool
+	keymap   *Keymap
+
+	// In text input mode the characters come from text input
+	// events, and only the keys that don't type anything are
+	// handled by ProcessKeyInput
+	textInput     bool
+	substitute    byte
+	hasSubstitute bool
 }
 
-// NewKeyboard creates a new keyboard
+// NewKeyboard creates a new keyboard with the US layout
 func NewKeyboard() *Keyboard {
-	return &Keyboard{capsLock: false, shiftOn: false}
+	return &Keyboard{capsLock: false, shiftOn: false, keymap: USKeymap()}
+}
+
+// SetKeymap changes the keyboard layout
+func (k *Keyboard) SetKeymap(keymap *Keymap) {
+	k.keymap = keymap
+}
+
+// SetTextInput switches text input mode on or off.  In text
+// input mode, characters the guest doesn't have are sent as the
+// substitute, e.g. "?" or "$7F", or dropped if it is empty.
+func (k *Keyboard) SetTextInput(enabled bool, substitute string) error {
+	k.textInput = enabled
+	k.hasSubstitute = substitute != ""
+	if !k.hasSubstitute {
+		return nil
+	}
+
+	b, err := parseGuestByte(substitute)
+	if err != nil {
+		return fmt.Errorf("Substitute character: %s", err)
+	}
+	k.substitute = b
+	return nil
+}
+
+// ProcessTextInput processes the text from a text input event,
+// returning the keys to send to the emulator.  Characters
+// outside 32-126 must be in the keymap's text section, or
+// they are replaced by the substitute.
+func (k *Keyboard) ProcessTextInput(text string) []rune {
+	var result []rune
+	for _, r := range text {
+		if b, ok := k.keymap.Text[r]; ok {
+			result = append(result, rune(b))
+		} else if r >= 32 && r <= 126 {
+			result = append(result, r)
+		} else if k.hasSubstitute {
+			result = append(result, rune(k.substitute))
+		}
+	}
+	return result
+}
+
+// Shortcut returns the emulator action for the key, if it is
+// one of the shortcuts, taking the modifiers held into account
+func (k *Keyboard) Shortcut(i *KeyInput, shortcuts Shortcuts) (string, bool) {
+	if i.Type != sdl.KEYDOWN {
+		return "", false
+	}
+	action, ok := shortcuts[Shortcut{Key: i.Key, Modifiers: k.modifiers()}]
+	return action, ok
 }
 
-// ProcessKeyInput processes a keyboard event
-func (k *Keyboard) ProcessKeyInput(i *KeyInput) rune {
+// ProcessKeyInput processes a keyboard event, returning the
+// keys to send to the emulator.  Most keys are a single
+// character, the arrows, function keys and so on are sent
+// as escape sequences.  With Ctrl held, keys are sent as ASCII
+// control codes, and with Alt held they are preceded by Escape,
+// as a terminal does.
+func (k *Keyboard) ProcessKeyInput(i *KeyInput) []rune {
 	r := i.Key
 	if i.Type == sdl.KEYDOWN {
 		switch {
-		case r == Backspace:
-			return r
-		case r == Escape:
-			return r
-		case r == Enter:
-			return r
+		case r == ascii.Backspace:
+			return k.withAlt([]rune{r})
+		case r == ascii.Escape:
+			return k.withAlt([]rune{r})
+		case r == ascii.Enter:
+			return k.withAlt([]rune{r})
 		case r == CapsLock:
 			k.capsLock = !k.capsLock
 		case r == LeftShift || r == RightShift:
 			k.shiftOn = true
-		case r < 32 || r > 126:
-			// Nothing
+		case r == LeftCtrl || r == RightCtrl:
+			k.ctrlOn = true
+		case r == LeftAlt || r == RightAlt:
+			k.altOn = true
+			k.altGrOn = r == RightAlt
+		case k.ctrlOn:
+			if c, ok := controlCode(r); ok {
+				return k.withAlt([]rune{c})
+			}
+			return k.withAlt(escapeSequence(r))
+		case k.textInput && (!k.altOn || k.altGrOn) && k.typesText(r):
+			// The character arrives in a text input event
 		default:
-			return k.runeCaps(r)
+			if b, ok := k.keymap.AltGr[r]; ok && k.altGrOn {
+				return []rune{rune(b)}
+			}
+			if b, ok := k.keymap.Lookup(r, k.shiftOn, k.capsLock); ok {
+				return k.withAlt([]rune{rune(b)})
+			}
+			return k.withAlt(escapeSequence(r))
+		}
+	} else if i.Type == sdl.KEYUP {
+		switch r {
+		case LeftShift, RightShift:
+			k.shiftOn = false
+		case LeftCtrl, RightCtrl:
+			k.ctrlOn = false
+		case LeftAlt, RightAlt:
+			k.altOn = false
+			k.altGrOn = false
 		}
-	} else if i.Type == sdl.KEYUP && (r == LeftShift || r == RightShift) { // left/right shift
-		k.shiftOn = false
 	}
 
-	return 0
+	return nil
 }
 
-func (k *Keyboard) runeCaps(r rune) rune {
-	if (r > 96 && r < 123) && (k.capsLock || k.shiftOn) && !(k.capsLock && k.shiftOn) {
-		return rune(strings.ToUpper(string(r))[0])
-	} else if k.shiftOn {
-		switch r {
-		case '0':
-			return ')'
-		case '1':
-			return '!'
-		case '2':
-			return '@'
-		case '3':
-			return '#'
-		case '4':
-			return '$'
-		case '5':
-			return '%'
-		case '6':
-			return '^'
-		case '7':
-			return '&'
-		case '8':
-			return '*'
-		case '9':
-			return '('
-		case '-':
-			return '_'
-		case '=':
-			return '+'
-		case '\\':
-			return '|'
-		case '[':
-			return '{'
-		case ']':
-			return '}'
-		case ';':
-			return ':'
-		case '\'':
-			return '"'
-		case ',':
-			return '<'
-		case '.':
-			return '>'
-		case '/':
-			return '?'
-		case '`':
-			return '~'
-		}
+// typesText returns true if the key types a character rather
+// than being a control or function key.  SDL gives the keys
+// without a character a code with K_SCANCODE_MASK set.
+func (k *Keyboard) typesText(r rune) bool {
+	_, ok := k.keymap.Lookup(r, k.shiftOn, k.capsLock)
+	return ok || (r > sdl.K_DELETE && r&sdl.K_SCANCODE_MASK == 0)
+}
+
+// modifiers returns the modifier keys being held down
+func (k *Keyboard) modifiers() Modifiers {
+	var result Modifiers
+	if k.shiftOn {
+		result |= ModShift
 	}
+	if k.ctrlOn {
+		result |= ModCtrl
+	}
+	if k.altOn {
+		result |= ModAlt
+	}
+	return result
+}
 
-	return r
+// withAlt puts Escape in front of the keys when Alt is held
+func (k *Keyboard) withAlt(keys []rune) []rune {
+	if !k.altOn || len(keys) == 0 {
+		return keys
+	}
+	return append([]rune{ascii.Escape}, keys...)
+}
+
+// controlCode returns the ASCII control code typed with Ctrl
+// and the key, following the usual terminal conventions, e.g.
+// Ctrl-2 for NUL and Ctrl-8 for DEL
+func controlCode(r rune) (rune, bool) {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return r - 'a' + 1, true
+	case r == ' ' || r == '2':
+		return 0, true
+	case r == '[' || r == '3':
+		return 27, true
+	case r == '\\' || r == '4':
+		return 28, true
+	case r == ']' || r == '5':
+		return 29, true
+	case r == '6':
+		return 30, true
+	case r == '-' || r == '/' || r == '7':
+		return 31, true
+	case r == '8':
+		return 127, true
+	}
+	return 0, false
 }
@@ -0,0 +1,227 @@
+package keyboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Keymap says which byte the guest receives for each host key,
+// on its own, with Shift and with AltGr (the right Alt key).  Keys
+// that aren't in the keymap are sent as they are, with the letters
+// in upper case for Shift or Caps Lock.  Text maps the characters
+// typed in text input mode.
+type Keymap struct {
+	Name  string
+	Keys  map[rune]byte
+	Shift map[rune]byte
+	AltGr map[rune]byte
+	Text  map[rune]byte
+}
+
+// usShift is the US keyboard's shifted symbols
+var usShift = map[rune]byte{
+	'0':  ')',
+	'1':  '!',
+	'2':  '@',
+	'3':  '#',
+	'4':  '$',
+	'5':  '%',
+	'6':  '^',
+	'7':  '&',
+	'8':  '*',
+	'9':  '(',
+	'-':  '_',
+	'=':  '+',
+	'\\': '|',
+	'[':  '{',
+	']':  '}',
+	';':  ':',
+	'\'': '"',
+	',':  '<',
+	'.':  '>',
+	'/':  '?',
+	'`':  '~',
+}
+
+// USKeymap returns the US layout, which is used unless
+// another keymap is loaded
+func USKeymap() *Keymap {
+	result := &Keymap{
+		Name:  "US",
+		Keys:  map[rune]byte{},
+		Shift: map[rune]byte{},
+		AltGr: map[rune]byte{},
+		Text:  map[rune]byte{},
+	}
+	for k, v := range usShift {
+		result.Shift[k] = v
+	}
+	return result
+}
+
+// keymapFile is the JSON form of a keymap, where the keys are
+// either a character or one of the names used in shortcuts, e.g.
+// "f1", and the values are a character or a number such as "$A3".
+// The keys of the text section are always characters.
+type keymapFile struct {
+	Name  string            `json:"name"`
+	Keys  map[string]string `json:"keys"`
+	Shift map[string]string `json:"shift"`
+	AltGr map[string]string `json:"altgr"`
+	Text  map[string]string `json:"text"`
+}
+
+// LoadKeymap reads a keymap from a JSON file.  The entries are
+// changes to the US layout, so only the keys that are different
+// need to be given.
+func LoadKeymap(filename string) (*Keymap, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := parseKeymap(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return result, nil
+}
+
+// parseKeymap reads a keymap in the JSON form.  Sections or
+// keys it doesn't know about are an error, rather than being
+// left out of the keymap without saying.
+func parseKeymap(data []byte) (*Keymap, error) {
+	var file keymapFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("Parsing keymap: %s", err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, fmt.Errorf("Parsing keymap: unexpected data after the keymap")
+	}
+
+	result := USKeymap()
+	result.Name = file.Name
+	if err := addMappings(result.Keys, file.Keys); err != nil {
+		return nil, err
+	}
+	if err := addMappings(result.Shift, file.Shift); err != nil {
+		return nil, err
+	}
+	if err := addMappings(result.AltGr, file.AltGr); err != nil {
+		return nil, err
+	}
+	for char, value := range file.Text {
+		r, size := utf8.DecodeRuneInString(char)
+		if size != len(char) || size == 0 || r == utf8.RuneError {
+			return nil, fmt.Errorf("'%s' in the text section must be a single character", char)
+		}
+		b, err := parseGuestByte(value)
+		if err != nil {
+			return nil, fmt.Errorf("Character '%s': %s", char, err)
+		}
+		result.Text[r] = b
+	}
+	return result, nil
+}
+
+// Lookup returns the byte to send for the key, or false if it
+// isn't a key that types a character
+func (m *Keymap) Lookup(r rune, shift, capsLock bool) (byte, bool) {
+	if r >= 'a' && r <= 'z' {
+		shift = shift != capsLock
+	}
+
+	if shift {
+		if b, ok := m.Shift[r]; ok {
+			return b, true
+		}
+	} else if b, ok := m.Keys[r]; ok {
+		return b, true
+	}
+
+	switch {
+	case r < 32 || r > 126:
+		return 0, false
+	case shift && r >= 'a' && r <= 'z':
+		return byte(r - 'a' + 'A'), true
+	default:
+		return byte(r), true
+	}
+}
+
+// addMappings adds a section of the JSON keymap.  Two names for
+// the same key, such as "A" and "a", are an error as it's not
+// clear which should win.
+func addMappings(to map[rune]byte, from map[string]string) error {
+	names := map[rune]string{}
+	for key, value := range from {
+		r, err := parseKey(key)
+		if err != nil {
+			return err
+		}
+		if other, ok := names[r]; ok {
+			return fmt.Errorf("Keys '%s' and '%s' are the same key", other, key)
+		}
+		names[r] = key
+
+		b, err := parseGuestByte(value)
+		if err != nil {
+			return fmt.Errorf("Key '%s': %s", key, err)
+		}
+		to[r] = b
+	}
+	return nil
+}
+
+// parseKey returns the key code for a single character or
+// one of the names in keyNames
+func parseKey(s string) (rune, error) {
+	if r, ok := keyNames[strings.ToLower(s)]; ok {
+		return r, nil
+	}
+	if r, size := utf8.DecodeRuneInString(s); size == len(s) && r > ' ' && r != utf8.RuneError {
+		// SDL gives letters in lower case, with Shift as
+		// a modifier
+		if r >= 'A' && r <= 'Z' {
+			r += 'a' - 'A'
+		}
+		return r, nil
+	}
+	return 0, fmt.Errorf("Unknown key '%s'", s)
+}
+
+// parseGuestByte converts a single character, taken as Latin-1
+// if it is above 127, or a number such as "$A3", "0xA3" or "163"
+// into the byte sent to the guest
+func parseGuestByte(s string) (byte, error) {
+	if r, size := utf8.DecodeRuneInString(s); size == len(s) && size > 0 && r != utf8.RuneError {
+		if r > 255 {
+			return 0, fmt.Errorf("'%s' isn't a Latin-1 character, give its byte as a number instead", s)
+		}
+		return byte(r), nil
+	}
+
+	str := s
+	base := 10
+	switch {
+	case strings.HasPrefix(str, "$"):
+		str = str[1:]
+		base = 16
+	case strings.HasPrefix(strings.ToLower(str), "0x"):
+		str = str[2:]
+		base = 16
+	}
+	result, err := strconv.ParseUint(str, base, 8)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid byte '%s'", s)
+	}
+	return byte(result), nil
+}
@@ -0,0 +1,119 @@
+package keyboard
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hculpan/go6502/ascii"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestParseKeymap(t *testing.T) {
+	keymap, err := parseKeymap([]byte(`{
+		"name": "Test",
+		"keys": {"Y": "z", "f1": "$80", "ä": "ä"},
+		"shift": {"2": "\"", "Escape": "0x1B"},
+		"altgr": {"q": "64"},
+		"text": {"€": "$A4"}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keymap.Name != "Test" {
+		t.Errorf("Name is %q", keymap.Name)
+	}
+
+	tests := []struct {
+		name     string
+		section  map[rune]byte
+		key      rune
+		expected byte
+	}{
+		{"letter", keymap.Keys, 'y', 'z'},
+		{"named key", keymap.Keys, sdl.K_F1, 0x80},
+		{"Latin-1", keymap.Keys, 'ä', 0xE4},
+		{"shift", keymap.Shift, '2', '"'},
+		{"shift named key", keymap.Shift, ascii.Escape, 0x1B},
+		{"US shift kept", keymap.Shift, '/', '?'},
+		{"altgr", keymap.AltGr, 'q', '@'},
+		{"text", keymap.Text, '€', 0xA4},
+	}
+	for _, test := range tests {
+		if b, ok := test.section[test.key]; !ok || b != test.expected {
+			t.Errorf("%s: got $%02X, %t, expected $%02X", test.name, b, ok, test.expected)
+		}
+	}
+}
+
+func TestLoadKeymaps(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "keymaps", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No keymaps found")
+	}
+	for _, file := range files {
+		if _, err := LoadKeymap(file); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestParseKeymapErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		json string
+	}{
+		{"bad JSON", `{"keys": {"a": "b"}`},
+		{"not an object", `["a", "b"]`},
+		{"wrong type", `{"keys": ["a", "b"]}`},
+		{"unknown section", `{"shfit": {"2": "@"}}`},
+		{"data after keymap", `{"keys": {"a": "b"}} {}`},
+		{"unknown key name", `{"keys": {"f13": "a"}}`},
+		{"empty key", `{"keys": {"": "a"}}`},
+		{"space", `{"keys": {" ": "a"}}`},
+		{"two characters", `{"shift": {"ab": "a"}}`},
+		{"same key twice", `{"keys": {"A": "x", "a": "y"}}`},
+		{"same named key twice", `{"altgr": {"Esc": "x", "escape": "y"}}`},
+		{"not Latin-1", `{"keys": {"e": "€"}}`},
+		{"byte too big", `{"keys": {"e": "$100"}}`},
+		{"bad number", `{"keys": {"e": "0xZZ"}}`},
+		{"empty value", `{"keys": {"e": ""}}`},
+		{"text key name", `{"text": {"f1": "a"}}`},
+		{"text value", `{"text": {"é": "256"}}`},
+	}
+
+	for _, test := range tests {
+		if _, err := parseKeymap([]byte(test.json)); err == nil {
+			t.Errorf("%s: no error", test.name)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	keymap := USKeymap()
+	keymap.Keys['y'] = 'z'
+
+	tests := []struct {
+		key             rune
+		shift, capsLock bool
+		expected        byte
+		ok              bool
+	}{
+		{'a', false, false, 'a', true},
+		{'a', true, false, 'A', true},
+		{'a', false, true, 'A', true},
+		{'a', true, true, 'a', true},
+		{'1', true, false, '!', true},
+		{'1', false, true, '1', true},
+		{'y', false, false, 'z', true},
+		{sdl.K_F1, false, false, 0, false},
+	}
+	for _, test := range tests {
+		b, ok := keymap.Lookup(test.key, test.shift, test.capsLock)
+		if b != test.expected || ok != test.ok {
+			t.Errorf("Lookup(%q, %t, %t) returned $%02X, %t", test.key, test.shift, test.capsLock, b, ok)
+		}
+	}
+}
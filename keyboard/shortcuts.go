@@ -0,0 +1,144 @@
+package keyboard
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hculpan/go6502/ascii"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// DefaultShortcuts are the keys the emulator has always kept
+// for itself, in the form accepted by ParseShortcuts
+const DefaultShortcuts = "F2=power,F3=debug,F5=pause,F6=step,F7=resume,F8=paste,F9=load,Escape=quit"
+
+// Modifiers are the modifier keys held down with a shortcut
+type Modifiers int
+
+// The modifier keys
+const (
+	ModShift Modifiers = 1 << iota
+	ModCtrl
+	ModAlt
+)
+
+// Shortcut is a key combination, such as Ctrl+Q, that the
+// emulator handles itself rather than sending to the guest
+type Shortcut struct {
+	Key       rune
+	Modifiers Modifiers
+}
+
+// Shortcuts maps key combinations to the names of the
+// emulator actions they trigger
+type Shortcuts map[Shortcut]string
+
+// keyNames are the keys without a character of their own,
+// as they are written in shortcuts
+var keyNames = map[string]rune{
+	"escape":    ascii.Escape,
+	"esc":       ascii.Escape,
+	"enter":     ascii.Enter,
+	"backspace": ascii.Backspace,
+	"tab":       sdl.K_TAB,
+	"space":     ' ',
+	"insert":    sdl.K_INSERT,
+	"delete":    sdl.K_DELETE,
+	"home":      sdl.K_HOME,
+	"end":       sdl.K_END,
+	"pageup":    sdl.K_PAGEUP,
+	"pagedown":  sdl.K_PAGEDOWN,
+	"up":        sdl.K_UP,
+	"down":      sdl.K_DOWN,
+	"left":      sdl.K_LEFT,
+	"right":     sdl.K_RIGHT,
+	"f1":        sdl.K_F1,
+	"f2":        sdl.K_F2,
+	"f3":        sdl.K_F3,
+	"f4":        sdl.K_F4,
+	"f5":        sdl.K_F5,
+	"f6":        sdl.K_F6,
+	"f7":        sdl.K_F7,
+	"f8":        sdl.K_F8,
+	"f9":        sdl.K_F9,
+	"f10":       sdl.K_F10,
+	"f11":       sdl.K_F11,
+	"f12":       sdl.K_F12,
+}
+
+var modifierNames = map[string]Modifiers{
+	"shift": ModShift,
+	"ctrl":  ModCtrl,
+	"alt":   ModAlt,
+}
+
+// ParseShortcut converts a key combination such as "F9",
+// "Ctrl+Q" or "Ctrl+Shift+PageUp" into a Shortcut
+func ParseShortcut(s string) (Shortcut, error) {
+	var result Shortcut
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	for _, part := range parts[:len(parts)-1] {
+		modifier, ok := modifierNames[part]
+		if !ok {
+			return Shortcut{}, fmt.Errorf("Unknown modifier '%s' in shortcut '%s'", part, s)
+		}
+		if result.Modifiers&modifier != 0 {
+			return Shortcut{}, fmt.Errorf("Modifier '%s' given twice in shortcut '%s'", part, s)
+		}
+		result.Modifiers |= modifier
+	}
+
+	key, err := parseKey(parts[len(parts)-1])
+	if err != nil {
+		return Shortcut{}, fmt.Errorf("%s in shortcut '%s'", err, s)
+	}
+	result.Key = key
+	return result, nil
+}
+
+// ParseShortcuts reads a comma separated list of shortcuts
+// and the actions they trigger, e.g. "F2=power,Ctrl+Q=quit".
+// An empty list means the emulator intercepts no keys.  Each
+// key combination can only be given once.
+func ParseShortcuts(s string) (Shortcuts, error) {
+	result := Shortcuts{}
+	if strings.TrimSpace(s) == "" {
+		return result, nil
+	}
+
+	for _, entry := range strings.Split(s, ",") {
+		if strings.TrimSpace(entry) == "" {
+			return nil, fmt.Errorf("Empty shortcut in '%s'", s)
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("Shortcut '%s' must be in the form key=action", entry)
+		}
+		shortcut, err := ParseShortcut(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		if action, ok := result[shortcut]; ok {
+			return nil, fmt.Errorf("Shortcut '%s' is already used for %s", strings.TrimSpace(parts[0]), action)
+		}
+		result[shortcut] = strings.TrimSpace(parts[1])
+	}
+	return result, nil
+}
+
+// Actions returns the names of the actions used by the
+// shortcuts, sorted, for checking against those supported
+func (s Shortcuts) Actions() []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, action := range s {
+		if !seen[action] {
+			seen[action] = true
+			result = append(result, action)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
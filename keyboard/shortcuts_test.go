@@ -0,0 +1,93 @@
+package keyboard
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hculpan/go6502/ascii"
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+func TestParseShortcut(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Shortcut
+	}{
+		{"F9", Shortcut{Key: sdl.K_F9}},
+		{"f9", Shortcut{Key: sdl.K_F9}},
+		{"Escape", Shortcut{Key: ascii.Escape}},
+		{"Esc", Shortcut{Key: ascii.Escape}},
+		{"Q", Shortcut{Key: 'q'}},
+		{"Ctrl+Q", Shortcut{Key: 'q', Modifiers: ModCtrl}},
+		{" ctrl+q ", Shortcut{Key: 'q', Modifiers: ModCtrl}},
+		{"Ctrl+Shift+PageUp", Shortcut{Key: sdl.K_PAGEUP, Modifiers: ModCtrl | ModShift}},
+		{"Shift+Alt+Ctrl+Space", Shortcut{Key: ' ', Modifiers: ModCtrl | ModShift | ModAlt}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseShortcut(test.spec)
+		if err != nil {
+			t.Errorf("%q: %s", test.spec, err)
+		} else if got != test.expected {
+			t.Errorf("%q: got %+v, expected %+v", test.spec, got, test.expected)
+		}
+	}
+}
+
+func TestParseShortcuts(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected Shortcuts
+	}{
+		{"", Shortcuts{}},
+		{" ", Shortcuts{}},
+		{"F2=power", Shortcuts{{Key: sdl.K_F2}: "power"}},
+		{"F2=power, Ctrl+Q = quit", Shortcuts{{Key: sdl.K_F2}: "power", {Key: 'q', Modifiers: ModCtrl}: "quit"}},
+		{"Q=quit,Ctrl+Q=power", Shortcuts{{Key: 'q'}: "quit", {Key: 'q', Modifiers: ModCtrl}: "power"}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseShortcuts(test.spec)
+		if err != nil {
+			t.Errorf("%q: %s", test.spec, err)
+		} else if !reflect.DeepEqual(got, test.expected) {
+			t.Errorf("%q: got %v, expected %v", test.spec, got, test.expected)
+		}
+	}
+}
+
+func TestDefaultShortcuts(t *testing.T) {
+	shortcuts, err := ParseShortcuts(DefaultShortcuts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"debug", "load", "paste", "pause", "power", "quit", "resume", "step"}
+	if got := shortcuts.Actions(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Actions %v, expected %v", got, expected)
+	}
+}
+
+func TestParseShortcutsErrors(t *testing.T) {
+	tests := []string{
+		"F2",
+		"F2=",
+		"=power",
+		"F2=power,",
+		"F2=power,,F3=debug",
+		"F13=power",
+		"Hyper+Q=quit",
+		"Ctrl+=quit",
+		"Ctrl+Ctrl+Q=quit",
+		"Ctrl++=quit",
+		"QQ=quit",
+		"F2=power,F2=quit",
+		"F2=power,f2=power",
+		"Ctrl+Shift+Q=quit,Shift+Ctrl+Q=power",
+	}
+
+	for _, spec := range tests {
+		if shortcuts, err := ParseShortcuts(spec); err == nil {
+			t.Errorf("%q: no error, got %v", spec, shortcuts)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package loader
+
+import "fmt"
+
+// MemoryImageAddress and MemoryImageSize describe a dump of all
+// memory above the zero page and stack, which loads without an
+// address
+const (
+	MemoryImageAddress = 0x0200
+	MemoryImageSize    = 0x10000 - MemoryImageAddress
+)
+
+// rawFormat is a plain binary file, loaded at the address it is
+// given.  The program starts at the first byte.
+var rawFormat = &Format{
+	Name:         "raw",
+	Extensions:   []string{".bin", ".rom", ".raw"},
+	TakesAddress: true,
+	Decode:       decodeRaw,
+}
+
+func decodeRaw(data []byte, opts Options) (*Image, error) {
+	if !opts.HasAddress {
+		if len(data) != MemoryImageSize {
+			return nil, fmt.Errorf("raw images need a load address, unless they are %d bytes for $%04X", MemoryImageSize, MemoryImageAddress)
+		}
+		// Memory images start through the reset vector
+		image := &Image{}
+		if err := image.add(MemoryImageAddress, data); err != nil {
+			return nil, err
+		}
+		return image, nil
+	}
+
+	image := &Image{}
+	if err := image.add(opts.Address, data); err != nil {
+		return nil, err
+	}
+	image.setEntry(opts.Address, false)
+	return image, nil
+}
+
+// sbinFormat is a binary file with a 4 byte header, the load
+// address followed by the length
+var sbinFormat = &Format{
+	Name:       "sbin",
+	Extensions: []string{".sbin"},
+	Decode:     decodeSBIN,
+}
+
+func decodeSBIN(data []byte, opts Options) (*Image, error) {
+	if len(data) < 4 {
+		return nil, fmt.Errorf("too short to have an sbin header")
+	}
+
+	image := &Image{}
+	if err := image.add(int(data[0])|int(data[1])<<8, data[4:]); err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// prgFormat is a binary file with the 2 byte load address in
+// front, as Commodore machines use.  The program starts at the
+// load address.
+var prgFormat = &Format{
+	Name:         "prg",
+	Extensions:   []string{".prg"},
+	TakesAddress: true,
+	Decode:       decodePRG,
+}
+
+func decodePRG(data []byte, opts Options) (*Image, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("too short to be a PRG file")
+	}
+	address := int(data[0]) | int(data[1])<<8
+	if opts.HasAddress {
+		address = opts.Address
+	}
+
+	image := &Image{}
+	if err := image.add(address, data[2:]); err != nil {
+		return nil, err
+	}
+	image.setEntry(address, false)
+	return image, nil
+}
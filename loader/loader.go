@@ -0,0 +1,253 @@
+// Package loader reads program and memory images in the formats
+// the emulator supports, returning the memory they fill rather
+// than writing it, so the emulator, the headless runner and the
+// tests can all use them.
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Segment is a block of bytes loaded at an address
+type Segment struct {
+	Address uint16
+	Data    []byte
+}
+
+// End returns the address after the last byte of the segment
+func (s Segment) End() int {
+	return int(s.Address) + len(s.Data)
+}
+
+// Image is a file decoded into the memory it fills
+type Image struct {
+	Format   string
+	Segments []Segment
+
+	// Entry is where the program starts, if HasEntry is set.
+	// AutoStart is set when the file itself says to start
+	// there, as HEX and S-record start records do, rather
+	// than it just being the start of the program.
+	Entry     uint16
+	HasEntry  bool
+	AutoStart bool
+
+	// Symbols exported by the file, if the format has them
+	Symbols map[string]uint16
+}
+
+// Covers returns true if the image loads the byte at address
+func (i *Image) Covers(address uint16) bool {
+	for _, s := range i.Segments {
+		if address >= s.Address && int(address) < s.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// Size returns the number of bytes in the image
+func (i *Image) Size() int {
+	result := 0
+	for _, s := range i.Segments {
+		result += len(s.Data)
+	}
+	return result
+}
+
+// add appends data at address, joining it to the last segment
+// if it follows on from it
+func (i *Image) add(address int, data []byte) error {
+	if address < 0 || address+len(data) > 0x10000 {
+		return fmt.Errorf("%d bytes at $%04X don't fit in the address space", len(data), address)
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	if n := len(i.Segments); n > 0 && i.Segments[n-1].End() == address {
+		i.Segments[n-1].Data = append(i.Segments[n-1].Data, data...)
+		return nil
+	}
+	i.Segments = append(i.Segments, Segment{Address: uint16(address), Data: append([]byte{}, data...)})
+	return nil
+}
+
+// setEntry sets where the program starts
+func (i *Image) setEntry(address int, autoStart bool) error {
+	if address < 0 || address > 0xFFFF {
+		return fmt.Errorf("start address $%X is outside the address space", address)
+	}
+	i.Entry = uint16(address)
+	i.HasEntry = true
+	i.AutoStart = autoStart
+	return nil
+}
+
+// Options change how an image is loaded
+type Options struct {
+	// Format is the name of the format to use, or "" to
+	// work it out from the file
+	Format string
+
+	// Address to load raw files at or to relocate programs
+	// to, if HasAddress is set
+	Address    int
+	HasAddress bool
+
+	// Skip leaves out the start of the file, and Length loads
+	// only that many bytes after it, or the rest if 0
+	Skip   int
+	Length int
+}
+
+// Format decodes one kind of image file
+type Format struct {
+	Name       string
+	Extensions []string // Lower case, including the dot
+
+	// Magic is what files of this format start with, or nil if
+	// they can only be recognised by their extension.  It must be
+	// long enough not to turn up at the start of other files.
+	Magic []byte
+
+	// TakesAddress is set if files can be loaded at, or
+	// relocated to, Options.Address.  The others load where
+	// the file says.
+	TakesAddress bool
+
+	Decode func(data []byte, opts Options) (*Image, error)
+}
+
+// formats are the registered formats, keyed by name
+var formats = map[string]*Format{}
+
+// extensions maps each extension to its format
+var extensions = map[string]*Format{}
+
+// Register adds a format to the ones Load recognises
+func Register(f *Format) error {
+	if _, ok := formats[f.Name]; ok {
+		return fmt.Errorf("Format %s is already registered", f.Name)
+	}
+	for _, ext := range f.Extensions {
+		if other, ok := extensions[ext]; ok {
+			return fmt.Errorf("Extension %s is already used by format %s", ext, other.Name)
+		}
+	}
+
+	formats[f.Name] = f
+	for _, ext := range f.Extensions {
+		extensions[ext] = f
+	}
+	return nil
+}
+
+// Lookup returns the format with the name
+func Lookup(name string) (*Format, error) {
+	if f, ok := formats[strings.ToLower(name)]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("Unknown image format '%s', expected one of %s", name, strings.Join(Names(), ", "))
+}
+
+// Names returns the names of the registered formats, sorted
+func Names() []string {
+	var result []string
+	for name := range formats {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Extensions returns the extensions of the registered formats,
+// sorted, for file dialogs
+func Extensions() []string {
+	var result []string
+	for ext := range extensions {
+		result = append(result, ext)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Detect works out the format of a file, first from its magic
+// bytes and then from its extension
+func Detect(filename string, data []byte) (*Format, error) {
+	for _, name := range Names() {
+		f := formats[name]
+		if f.Magic != nil && bytes.HasPrefix(data, f.Magic) {
+			return f, nil
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if f, ok := extensions[ext]; ok {
+		return f, nil
+	}
+	return nil, fmt.Errorf("Unrecognized file type '%s'", ext)
+}
+
+// Load reads an image file
+func Load(filename string, opts Options) (*Image, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return LoadData(filename, data, opts)
+}
+
+// LoadData decodes the contents of an image file, using the
+// filename to recognise the format and in errors
+func LoadData(filename string, data []byte, opts Options) (*Image, error) {
+	if opts.Skip < 0 || opts.Length < 0 {
+		return nil, fmt.Errorf("%s: skip and length can't be negative", filename)
+	}
+	if opts.Skip > len(data) {
+		return nil, fmt.Errorf("%s: can't skip %d bytes of a %d byte file", filename, opts.Skip, len(data))
+	}
+	data = data[opts.Skip:]
+	if opts.Length > 0 {
+		if opts.Length > len(data) {
+			return nil, fmt.Errorf("%s: only %d bytes left to load, not %d", filename, len(data), opts.Length)
+		}
+		data = data[:opts.Length]
+	}
+
+	var f *Format
+	var err error
+	if opts.Format != "" {
+		f, err = Lookup(opts.Format)
+	} else {
+		f, err = Detect(filename, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if opts.HasAddress && !f.TakesAddress {
+		return nil, fmt.Errorf("%s: %s images load at the address in the file, they can't be given one", filename, f.Name)
+	}
+	if opts.HasAddress && (opts.Address < 0 || opts.Address > 0xFFFF) {
+		return nil, fmt.Errorf("%s: address must be between $0000 and $FFFF", filename)
+	}
+
+	image, err := f.Decode(data, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	image.Format = f.Name
+	return image, nil
+}
+
+func init() {
+	for _, f := range []*Format{rawFormat, sbinFormat, txtFormat, hexFormat, srecFormat, prgFormat, o65Format} {
+		if err := Register(f); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package loader
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// hexLine makes an Intel HEX record from the length, address,
+// type and data, adding the checksum
+func hexLine(record ...byte) string {
+	return fmt.Sprintf(":%X%02X\n", record, -checksum(record))
+}
+
+// srecLine makes an S-record of the type from the address and
+// data, adding the count and checksum
+func srecLine(kind byte, record ...byte) string {
+	record = append([]byte{byte(len(record) + 1)}, record...)
+	return fmt.Sprintf("S%c%X%02X\n", kind, record, ^checksum(record))
+}
+
+// o65Header is the header of an o65 file with a 3 byte text
+// segment assembled at $1000 and no data or bss
+var o65Header = []byte{
+	0x01, 0x00, 'o', '6', '5', 0x00,
+	0x00, 0x00, // Mode
+	0x00, 0x10, 0x03, 0x00, // Text base and length
+	0x00, 0x00, 0x00, 0x00, // Data
+	0x03, 0x10, 0x00, 0x00, // Bss
+	0x00, 0x00, 0x00, 0x00, // Zero page
+	0x00, 0x00, // Stack
+	0x00, // End of header options
+}
+
+// o65File is a program that jumps to itself, with the JMP's
+// address relocated and a "start" global
+func o65File() []byte {
+	var result []byte
+	result = append(result, o65Header...)
+	result = append(result, 0x4C, 0x00, 0x10) // JMP $1000
+	result = append(result, 0x00, 0x00)       // Undefined references
+	result = append(result, 0x02, 0x82, 0x00) // Word at offset 1 in text
+	result = append(result, 0x00)             // Data relocation
+	result = append(result, 0x01, 0x00, 's', 't', 'a', 'r', 't', 0x00, 0x02, 0x00, 0x10)
+	return result
+}
+
+// withByte returns a copy of data with one byte changed
+func withByte(data []byte, pos int, b byte) []byte {
+	result := append([]byte{}, data...)
+	result[pos] = b
+	return result
+}
+
+func TestMalformed(t *testing.T) {
+	o65 := o65File()
+	tests := []struct {
+		name     string
+		filename string
+		data     string
+		options  Options
+		err      string
+	}{
+		{"unknown extension", "rom.xyz", "", Options{}, "Unrecognized file type '.xyz'"},
+		{"unknown format", "rom.bin", "", Options{Format: "elf"}, "Unknown image format 'elf'"},
+		{"skip past end", "rom.bin", "abc", Options{Address: 0x1000, HasAddress: true, Skip: 4}, "can't skip 4 bytes of a 3 byte file"},
+		{"length past end", "rom.bin", "abc", Options{Address: 0x1000, HasAddress: true, Skip: 1, Length: 3}, "only 2 bytes left to load, not 3"},
+		{"address too high", "rom.bin", "abc", Options{Address: 0x10000, HasAddress: true}, "address must be between $0000 and $FFFF"},
+
+		{"raw without address", "rom.bin", "abc", Options{}, "raw images need a load address"},
+		{"raw past end of memory", "rom.bin", "abc", Options{Address: 0xFFFE, HasAddress: true}, "3 bytes at $FFFE don't fit"},
+		{"sbin header", "rom.sbin", "\x00\x02\x00", Options{}, "too short to have an sbin header"},
+		{"sbin given address", "rom.sbin", "\x00\x02\x00\x00", Options{HasAddress: true}, "sbin images load at the address in the file"},
+		{"prg header", "prog.prg", "\x01", Options{}, "too short to be a PRG file"},
+		{"prg past end of memory", "prog.prg", "\xFF\xFFab", Options{}, "2 bytes at $FFFF don't fit"},
+
+		{"txt short address", "rom.txt", "200 A9 00", Options{}, "line 1: address '200' must be 4 hex digits"},
+		{"txt address", "rom.txt", "0200: A9\n02G0: 00", Options{}, "line 2: invalid address '02G0'"},
+		{"txt data", "rom.txt", "0200: A9 100", Options{}, "line 1: invalid data '100'"},
+
+		{"hex start", "rom.hex", "0100000001FF", Options{}, "line 1: record doesn't start with ':'"},
+		{"hex odd digits", "rom.hex", ":0100000001F", Options{}, "odd number of hex digits"},
+		{"hex digits", "rom.hex", ":01000000XXFF", Options{}, "invalid hex digits 'XX'"},
+		{"hex checksum", "rom.hex", ":0100000001FF\n", Options{}, "line 1: bad checksum"},
+		{"hex length", "rom.hex", hexLine(0x02, 0x00, 0x00, 0x00, 0x01), Options{}, "record length doesn't match its data"},
+		{"hex record type", "rom.hex", hexLine(0x00, 0x00, 0x00, 0x06), Options{}, "unknown record type 06"},
+		{"hex missing end", "rom.hex", hexLine(0x01, 0x02, 0x00, 0x00, 0xEA), Options{}, "missing end of file record"},
+		{"hex past end of memory", "rom.hex", hexLine(0x02, 0xFF, 0xFF, 0x00, 0xEA, 0xEA), Options{}, "2 bytes at $FFFF don't fit"},
+		{"hex extended address", "rom.hex", hexLine(0x02, 0x00, 0x00, 0x04, 0x00, 0x01) + hexLine(0x01, 0x00, 0x00, 0x00, 0xEA), Options{}, "line 2: 1 bytes at $10000 don't fit"},
+		{"hex start address", "rom.hex", hexLine(0x04, 0x00, 0x00, 0x05, 0x00, 0x01, 0x00, 0x00), Options{}, "start address $10000 is outside the address space"},
+		{"hex segment length", "rom.hex", hexLine(0x01, 0x00, 0x00, 0x02, 0x00), Options{}, "segment address must be 2 bytes"},
+		{"hex given address", "rom.hex", hexLine(0x00, 0x00, 0x00, 0x01), Options{HasAddress: true}, "hex images load at the address in the file"},
+
+		{"srec start", "rom.s19", "X1030200EA", Options{}, "line 1: record doesn't start with 'S'"},
+		{"srec checksum", "rom.s19", "S1040200EA00", Options{}, "bad checksum"},
+		{"srec count", "rom.s19", "S1050200EA0E", Options{}, "record length doesn't match its data"},
+		{"srec record type", "rom.s19", srecLine('4', 0x00, 0x00), Options{}, "unknown record type S4"},
+		{"srec too short", "rom.s19", srecLine('2', 0x00, 0x00), Options{}, "record too short"},
+		{"srec past end of memory", "rom.s28", srecLine('2', 0x00, 0xFF, 0xFF, 0xEA, 0xEA), Options{}, "2 bytes at $FFFF don't fit"},
+		{"srec start address", "rom.s37", srecLine('7', 0x00, 0x01, 0x00, 0x00), Options{}, "start address $10000 is outside the address space"},
+
+		{"o65 magic", "prog.o65", "\x01\x00o64\x00\x00\x00", Options{}, "not an o65 file"},
+		{"o65 header", "prog.o65", string(o65Header[:12]), Options{}, "header: file ends at byte 12"},
+		{"o65 65816", "prog.o65", string(withByte(o65, 7, 0x80)), Options{}, "65816 programs are not supported"},
+		{"o65 text", "prog.o65", string(o65[:len(o65Header)+2]), Options{}, "file ends before the 3 bytes"},
+		{"o65 undefined", "prog.o65", string(withByte(o65, len(o65Header)+3, 0x01)), Options{}, "references undefined symbols"},
+		{"o65 relocation offset", "prog.o65", string(withByte(o65, len(o65Header)+5, 0x03)), Options{}, "text relocation: offset 2 is outside the segment"},
+		{"o65 relocation segment", "prog.o65", string(withByte(o65, len(o65Header)+6, 0x80)), Options{}, "text relocation: unsupported segment 0"},
+		{"o65 globals", "prog.o65", string(o65[:len(o65)-2]), Options{}, "exported globals: file ends"},
+		{"o65 past end of memory", "prog.o65", string(o65), Options{Address: 0xFFFE, HasAddress: true}, "doesn't fit in the address space at $FFFE"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			image, err := LoadData(test.filename, []byte(test.data), test.options)
+			if err == nil {
+				t.Fatalf("Loaded %d bytes, expected error containing %q", image.Size(), test.err)
+			}
+			if !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("Error %q, expected it to contain %q", err, test.err)
+			}
+			if !strings.HasPrefix(err.Error(), test.filename+": ") {
+				t.Errorf("Error %q doesn't start with the filename", err)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		filename string
+		data     []byte
+		format   string
+	}{
+		{"rom.bin", nil, "raw"},
+		{"ROM.BIN", nil, "raw"},
+		{"rom.sbin", nil, "sbin"},
+		{"rom.txt", nil, "txt"},
+		{"rom.ihx", nil, "hex"},
+		{"rom.s28", nil, "srec"},
+		{"prog.prg", nil, "prg"},
+		{"prog.o65", nil, "o65"},
+		{"prog.bin", o65File(), "o65"},
+	}
+
+	for _, test := range tests {
+		format, err := Detect(test.filename, test.data)
+		if err != nil {
+			t.Errorf("%s: %s", test.filename, err)
+		} else if format.Name != test.format {
+			t.Errorf("%s: detected %s, expected %s", test.filename, format.Name, test.format)
+		}
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	if err := Register(&Format{Name: "raw"}); err == nil {
+		t.Error("Registered a second raw format")
+	}
+	if err := Register(&Format{Name: "other", Extensions: []string{".hex"}}); err == nil {
+		t.Error("Registered a second format for .hex")
+	}
+}
+
+// checkImage compares the segments and entry point of an image
+func checkImage(t *testing.T, image *Image, segments []Segment, entry uint16, hasEntry, autoStart bool) {
+	t.Helper()
+	if len(image.Segments) != len(segments) {
+		t.Fatalf("Got %d segments, expected %d", len(image.Segments), len(segments))
+	}
+	for i, s := range segments {
+		got := image.Segments[i]
+		if got.Address != s.Address || !bytes.Equal(got.Data, s.Data) {
+			t.Errorf("Segment %d is %X at $%04X, expected %X at $%04X", i, got.Data, got.Address, s.Data, s.Address)
+		}
+	}
+	if image.HasEntry != hasEntry || image.AutoStart != autoStart || (hasEntry && image.Entry != entry) {
+		t.Errorf("Entry $%04X (%t, auto start %t), expected $%04X (%t, auto start %t)",
+			image.Entry, image.HasEntry, image.AutoStart, entry, hasEntry, autoStart)
+	}
+}
+
+func TestHEX(t *testing.T) {
+	data := hexLine(0x02, 0x02, 0x00, 0x00, 0xA9, 0x00) +
+		hexLine(0x01, 0x02, 0x02, 0x00, 0xEA) +
+		hexLine(0x02, 0xFF, 0xFC, 0x00, 0x00, 0x02) +
+		hexLine(0x04, 0x00, 0x00, 0x05, 0x00, 0x00, 0x02, 0x00) +
+		hexLine(0x00, 0x00, 0x00, 0x01) +
+		"ignored after the end\n"
+
+	image, err := LoadData("rom.hex", []byte(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{
+		{0x0200, []byte{0xA9, 0x00, 0xEA}},
+		{0xFFFC, []byte{0x00, 0x02}},
+	}, 0x0200, true, true)
+	if !image.Covers(0xFFFD) || image.Covers(0x0203) {
+		t.Error("Covers doesn't match the segments")
+	}
+}
+
+func TestSREC(t *testing.T) {
+	data := srecLine('0', 0x00, 0x00, 'h', 'i') +
+		srecLine('1', 0x02, 0x00, 0xA9, 0x00) +
+		srecLine('1', 0x02, 0x02, 0xEA) +
+		srecLine('9', 0x00, 0x00)
+
+	image, err := LoadData("rom.s19", []byte(data), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A start address of 0 means there isn't one
+	checkImage(t, image, []Segment{{0x0200, []byte{0xA9, 0x00, 0xEA}}}, 0, false, false)
+}
+
+func TestTXTAndSBIN(t *testing.T) {
+	image, err := LoadData("rom.txt", []byte("0200: A9 00\r\n\r\n0300: EA\r\n"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0x0200, []byte{0xA9, 0x00}}, {0x0300, []byte{0xEA}}}, 0, false, false)
+
+	// Retro Assembler doesn't put a colon after the address
+	image, err = LoadData("rom.txt", []byte("0200 A9 00\n0202\tEA\n"), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0x0200, []byte{0xA9, 0x00, 0xEA}}}, 0, false, false)
+
+	image, err = LoadData("rom.sbin", []byte{0x00, 0x03, 0x01, 0x00, 0xEA}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0x0300, []byte{0xEA}}}, 0, false, false)
+}
+
+func TestAssembledTXT(t *testing.T) {
+	files, err := filepath.Glob("../asm/*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("No programs in ../asm")
+	}
+	for _, f := range files {
+		if _, err := Load(f, Options{}); err != nil {
+			t.Error(err)
+		}
+	}
+
+	image, err := Load("../asm/hello_world.txt", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(image.Segments) != 3 {
+		t.Fatalf("Loaded %d segments, expected 3", len(image.Segments))
+	}
+	code := image.Segments[0]
+	if code.Address != 0x9000 || len(code.Data) != 0x2F || !bytes.Equal(code.Data[:4], []byte{0x58, 0x4C, 0x13, 0x90}) {
+		t.Errorf("Code is % X at $%04X", code.Data, code.Address)
+	}
+	vectors := image.Segments[2]
+	if vectors.Address != 0xFFFC || !bytes.Equal(vectors.Data, []byte{0x00, 0x90, 0x00, 0xF0}) {
+		t.Errorf("Vectors are % X at $%04X", vectors.Data, vectors.Address)
+	}
+}
+
+func TestRawAndPRG(t *testing.T) {
+	image, err := LoadData("dump.bin", []byte("abcdef"), Options{Address: 0x0800, HasAddress: true, Skip: 1, Length: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0x0800, []byte("bcd")}}, 0x0800, true, false)
+
+	image, err = LoadData("rom.bin", make([]byte, MemoryImageSize), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{MemoryImageAddress, make([]byte, MemoryImageSize)}}, 0, false, false)
+
+	image, err = LoadData("prog.prg", []byte{0x01, 0x08, 0xEA}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0x0801, []byte{0xEA}}}, 0x0801, true, false)
+
+	image, err = LoadData("prog.prg", []byte{0x01, 0x08, 0xEA}, Options{Address: 0xC000, HasAddress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0xC000, []byte{0xEA}}}, 0xC000, true, false)
+}
+
+func TestO65(t *testing.T) {
+	image, err := LoadData("prog.o65", o65File(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0x1000, []byte{0x4C, 0x00, 0x10}}}, 0x1000, true, false)
+
+	image, err = LoadData("prog.o65", o65File(), Options{Address: 0x2080, HasAddress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImage(t, image, []Segment{{0x2080, []byte{0x4C, 0x80, 0x20}}}, 0x2080, true, false)
+	if image.Symbols["start"] != 0x2080 {
+		t.Errorf("start is $%04X, expected $2080", image.Symbols["start"])
+	}
+}
@@ -0,0 +1,298 @@
+package loader
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// o65 mode bits
+const (
+	o65Mode65816 = 0x8000
+	o65ModePaged = 0x4000
+	o65ModeLong  = 0x2000 // Sizes and addresses are 32 bits
+	o65ModeBSS   = 0x0200 // The bss segment must be cleared
+)
+
+// o65 relocation entry types, in the top 3 bits
+const (
+	o65RelocWord   = 0x80
+	o65RelocHigh   = 0x40
+	o65RelocLow    = 0x20
+	o65RelocSegAdr = 0xC0
+	o65RelocSeg    = 0xA0
+)
+
+// o65 segment IDs
+const (
+	o65SegUndefined = 0
+	o65SegAbsolute  = 1
+	o65SegText      = 2
+	o65SegData      = 3
+	o65SegBSS       = 4
+	o65SegZero      = 5
+)
+
+var o65Magic = []byte{0x01, 0x00, 'o', '6', '5'}
+
+// o65Format is André Fachat's relocatable object format.  It is
+// loaded where it was assembled unless given an address, and
+// the program starts at the beginning of the text segment.
+var o65Format = &Format{
+	Name:         "o65",
+	Extensions:   []string{".o65"},
+	Magic:        o65Magic,
+	TakesAddress: true,
+	Decode:       decodeO65,
+}
+
+func decodeO65(data []byte, opts Options) (*Image, error) {
+	base := -1
+	if opts.HasAddress {
+		base = opts.Address
+	}
+	program, err := relocateO65(data, base)
+	if err != nil {
+		return nil, err
+	}
+
+	image := &Image{Symbols: map[string]uint16{}}
+	if err := image.add(program.textBase, program.text); err != nil {
+		return nil, err
+	}
+	if err := image.add(program.dataBase, program.data); err != nil {
+		return nil, err
+	}
+	if program.clearBSS {
+		if err := image.add(program.bssBase, make([]byte, program.bssLen)); err != nil {
+			return nil, err
+		}
+	}
+	image.setEntry(program.textBase, false)
+	for name, value := range program.globals {
+		image.Symbols[name] = uint16(value)
+	}
+	return image, nil
+}
+
+// o65Program is an o65 file relocated to where it will run,
+// see http://www.6502.org/users/andre/o65/fileformat.html
+type o65Program struct {
+	textBase int
+	text     []byte
+	dataBase int
+	data     []byte
+	bssBase  int
+	bssLen   int
+	clearBSS bool
+	globals  map[string]int // Exported symbols, relocated
+}
+
+// o65Reader reads the fields of an o65 file, which are 16 or
+// 32 bits depending on the mode
+type o65Reader struct {
+	data []byte
+	pos  int
+	long bool
+	err  error
+}
+
+func (r *o65Reader) byte() int {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.data) {
+		r.err = fmt.Errorf("file ends at byte %d", r.pos)
+		return 0
+	}
+	r.pos++
+	return int(r.data[r.pos-1])
+}
+
+func (r *o65Reader) word() int {
+	return r.byte() | r.byte()<<8
+}
+
+// size reads a size or address field
+func (r *o65Reader) size() int {
+	if r.long {
+		return r.word() | r.word()<<16
+	}
+	return r.word()
+}
+
+func (r *o65Reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("file ends before the %d bytes at byte %d", n, r.pos)
+		return nil
+	}
+	r.pos += n
+	return r.data[r.pos-n : r.pos]
+}
+
+func (r *o65Reader) name() string {
+	if r.err != nil {
+		return ""
+	}
+	end := bytes.IndexByte(r.data[r.pos:], 0)
+	if end < 0 {
+		r.err = fmt.Errorf("name at byte %d isn't terminated", r.pos)
+		return ""
+	}
+	r.pos += end + 1
+	return string(r.data[r.pos-end-1 : r.pos-1])
+}
+
+// relocateO65 reads an o65 file and relocates it so the text
+// segment starts at base, with the data and bss segments straight
+// after it.  A base of -1 leaves the program where it was
+// assembled.  Zero page addresses are never moved.
+func relocateO65(data []byte, base int) (*o65Program, error) {
+	if len(data) < 8 || !bytes.Equal(data[:5], o65Magic) {
+		return nil, fmt.Errorf("not an o65 file")
+	}
+	r := &o65Reader{data: data, pos: 6}
+
+	mode := r.word()
+	if mode&o65Mode65816 != 0 {
+		return nil, fmt.Errorf("65816 programs are not supported")
+	}
+	r.long = mode&o65ModeLong != 0
+
+	tbase, tlen := r.size(), r.size()
+	dbase, dlen := r.size(), r.size()
+	bbase, blen := r.size(), r.size()
+	r.size() // zbase
+	r.size() // zlen
+	r.size() // stack
+	if r.err != nil {
+		return nil, fmt.Errorf("header: %s", r.err)
+	}
+
+	// Header options, each starting with its length,
+	// ending with a length of 0
+	for {
+		n := r.byte()
+		if n == 0 || r.err != nil {
+			break
+		}
+		r.bytes(n - 1)
+	}
+
+	result := &o65Program{
+		textBase: tbase,
+		text:     append([]byte{}, r.bytes(tlen)...),
+		dataBase: dbase,
+		data:     append([]byte{}, r.bytes(dlen)...),
+		bssBase:  bbase,
+		bssLen:   blen,
+		clearBSS: mode&o65ModeBSS != 0,
+		globals:  map[string]int{},
+	}
+	if base >= 0 {
+		result.textBase = base
+		result.dataBase = base + tlen
+		result.bssBase = base + tlen + dlen
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if result.bssBase+blen > 0x10000 {
+		return nil, fmt.Errorf("program doesn't fit in the address space at $%04X", result.textBase)
+	}
+
+	delta := map[int]int{
+		o65SegAbsolute: 0,
+		o65SegText:     result.textBase - tbase,
+		o65SegData:     result.dataBase - dbase,
+		o65SegBSS:      result.bssBase - bbase,
+		o65SegZero:     0,
+	}
+
+	undefined := r.size()
+	var names []string
+	for i := 0; i < undefined && r.err == nil; i++ {
+		names = append(names, r.name())
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("undefined references: %s", r.err)
+	}
+	if len(names) > 0 {
+		return nil, fmt.Errorf("references undefined symbols %v, it must be linked first", names)
+	}
+
+	if err := relocateO65Segment(r, result.text, delta, mode&o65ModePaged != 0); err != nil {
+		return nil, fmt.Errorf("text relocation: %s", err)
+	}
+	if err := relocateO65Segment(r, result.data, delta, mode&o65ModePaged != 0); err != nil {
+		return nil, fmt.Errorf("data relocation: %s", err)
+	}
+
+	globals := r.size()
+	for i := 0; i < globals && r.err == nil; i++ {
+		name := r.name()
+		segment := r.byte()
+		value := r.size()
+		if d, ok := delta[segment]; ok {
+			result.globals[name] = value + d
+		}
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("exported globals: %s", r.err)
+	}
+
+	return result, nil
+}
+
+// relocateO65Segment applies a relocation table to the segment
+func relocateO65Segment(r *o65Reader, segment []byte, delta map[int]int, paged bool) error {
+	pos := -1
+	for {
+		offset := r.byte()
+		if offset == 0 || r.err != nil {
+			break
+		}
+		if offset == 255 {
+			pos += 254
+			continue
+		}
+		pos += offset
+
+		kind := r.byte()
+		id := kind & 0x1F
+		d, ok := delta[id]
+		if !ok || id == o65SegUndefined {
+			return fmt.Errorf("unsupported segment %d at offset %d", id, pos)
+		}
+
+		switch kind & 0xE0 {
+		case o65RelocWord:
+			if pos < 0 || pos+1 >= len(segment) {
+				return fmt.Errorf("offset %d is outside the segment", pos)
+			}
+			value := int(binary.LittleEndian.Uint16(segment[pos:])) + d
+			binary.LittleEndian.PutUint16(segment[pos:], uint16(value))
+		case o65RelocHigh:
+			if pos < 0 || pos >= len(segment) {
+				return fmt.Errorf("offset %d is outside the segment", pos)
+			}
+			low := 0
+			if !paged {
+				low = r.byte()
+			}
+			value := (int(segment[pos])<<8 | low) + d
+			segment[pos] = byte(value >> 8)
+		case o65RelocLow:
+			if pos < 0 || pos >= len(segment) {
+				return fmt.Errorf("offset %d is outside the segment", pos)
+			}
+			segment[pos] = byte(int(segment[pos]) + d)
+		default:
+			return fmt.Errorf("unsupported relocation type $%02X at offset %d", kind&0xE0, pos)
+		}
+	}
+	return r.err
+}
@@ -0,0 +1,239 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// txtFormat is the text output of Retro Assembler, lines of a
+// 4 digit hex address and the hex bytes, e.g.
+// "0200 A9 00 8D 00 80".  A colon after the address, as in
+// "0200: A9 00", is also accepted.
+var txtFormat = &Format{
+	Name:       "txt",
+	Extensions: []string{".txt"},
+	Decode:     decodeTXT,
+}
+
+func decodeTXT(data []byte, opts Options) (*Image, error) {
+	image := &Image{}
+	err := scanLines(data, func(line string) error {
+		addrStr := line
+		if end := strings.IndexAny(line, ": \t"); end >= 0 {
+			addrStr = line[:end]
+		}
+		if len(addrStr) != 4 {
+			return fmt.Errorf("address '%s' must be 4 hex digits", addrStr)
+		}
+		addr, err := strconv.ParseUint(addrStr, 16, 16)
+		if err != nil {
+			return fmt.Errorf("invalid address '%s'", addrStr)
+		}
+
+		var values []byte
+		for _, v := range strings.Fields(strings.TrimPrefix(line[4:], ":")) {
+			b, err := strconv.ParseUint(v, 16, 8)
+			if err != nil {
+				return fmt.Errorf("invalid data '%s'", v)
+			}
+			values = append(values, byte(b))
+		}
+		return image.add(int(addr), values)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// hexFormat is Intel HEX.  A start address record gives the
+// entry point.
+var hexFormat = &Format{
+	Name:       "hex",
+	Extensions: []string{".hex", ".ihx"},
+	Decode:     decodeHEX,
+}
+
+func decodeHEX(data []byte, opts Options) (*Image, error) {
+	image := &Image{}
+	base := 0 // From the extended address records
+	ended := false
+	err := scanLines(data, func(line string) error {
+		if ended {
+			// Anything after the end isn't part of the image
+			return nil
+		}
+		if line[0] != ':' {
+			return fmt.Errorf("record doesn't start with ':'")
+		}
+		record, err := decodeRecord(line[1:])
+		if err != nil {
+			return err
+		}
+
+		// Length, address high, address low, type, data, checksum
+		if len(record) < 5 || int(record[0]) != len(record)-5 {
+			return fmt.Errorf("record length doesn't match its data")
+		}
+		if checksum(record) != 0 {
+			return fmt.Errorf("bad checksum")
+		}
+		address := int(record[1])<<8 | int(record[2])
+		data := record[4 : len(record)-1]
+
+		switch record[3] {
+		case 0x00: // Data
+			return image.add(base+address, data)
+		case 0x01: // End of file
+			ended = true
+		case 0x02: // Extended segment address
+			if len(data) != 2 {
+				return fmt.Errorf("segment address must be 2 bytes")
+			}
+			base = (int(data[0])<<8 | int(data[1])) << 4
+		case 0x03: // Start segment address, CS:IP
+			if len(data) != 4 {
+				return fmt.Errorf("start address must be 4 bytes")
+			}
+			return image.setEntry((int(data[0])<<8|int(data[1]))<<4+(int(data[2])<<8|int(data[3])), true)
+		case 0x04: // Extended linear address
+			if len(data) != 2 {
+				return fmt.Errorf("linear address must be 2 bytes")
+			}
+			base = (int(data[0])<<8 | int(data[1])) << 16
+		case 0x05: // Start linear address
+			if len(data) != 4 {
+				return fmt.Errorf("start address must be 4 bytes")
+			}
+			return image.setEntry(int(data[0])<<24|int(data[1])<<16|int(data[2])<<8|int(data[3]), true)
+		default:
+			return fmt.Errorf("unknown record type %02X", record[3])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !ended {
+		return nil, fmt.Errorf("missing end of file record")
+	}
+	return image, nil
+}
+
+// srecFormat is Motorola S-records, S19, S28 or S37.  A start
+// address record gives the entry point.
+var srecFormat = &Format{
+	Name:       "srec",
+	Extensions: []string{".s19", ".s28", ".s37", ".srec", ".mot"},
+	Decode:     decodeSREC,
+}
+
+func decodeSREC(data []byte, opts Options) (*Image, error) {
+	image := &Image{}
+	ended := false
+	err := scanLines(data, func(line string) error {
+		if ended {
+			return nil
+		}
+		if len(line) < 2 || line[0] != 'S' {
+			return fmt.Errorf("record doesn't start with 'S'")
+		}
+		record, err := decodeRecord(line[2:])
+		if err != nil {
+			return err
+		}
+
+		// Count, address, data, checksum.  The checksum is the
+		// ones' complement of the sum of the rest.
+		if len(record) < 1 || int(record[0]) != len(record)-1 {
+			return fmt.Errorf("record length doesn't match its data")
+		}
+		if checksum(record) != 0xFF {
+			return fmt.Errorf("bad checksum")
+		}
+
+		var addressSize int
+		switch line[1] {
+		case '0', '1', '5', '9':
+			addressSize = 2
+		case '2', '6', '8':
+			addressSize = 3
+		case '3', '7':
+			addressSize = 4
+		default:
+			return fmt.Errorf("unknown record type S%c", line[1])
+		}
+		if len(record) < addressSize+2 {
+			return fmt.Errorf("record too short")
+		}
+		address := 0
+		for _, b := range record[1 : addressSize+1] {
+			address = address<<8 | int(b)
+		}
+		data := record[addressSize+1 : len(record)-1]
+
+		switch line[1] {
+		case '1', '2', '3': // Data
+			return image.add(address, data)
+		case '7', '8', '9': // Start address, which ends the file
+			ended = true
+			// Most tools write 0 when there's no start address
+			if address != 0 {
+				return image.setEntry(address, true)
+			}
+		}
+		// S0 is the header and S5/S6 the record count, neither
+		// of which we need
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return image, nil
+}
+
+// scanLines calls parse with each line of the file that isn't
+// blank, adding the line number to any error
+func scanLines(data []byte, parse func(line string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 {
+			continue
+		}
+		if err := parse(line); err != nil {
+			return fmt.Errorf("line %d: %s", lineNo, err)
+		}
+	}
+	return scanner.Err()
+}
+
+// decodeRecord converts the hex digits of a HEX or S-record
+// line into bytes
+func decodeRecord(s string) ([]byte, error) {
+	if len(s)%2 != 0 {
+		return nil, fmt.Errorf("odd number of hex digits")
+	}
+
+	result := make([]byte, len(s)/2)
+	for i := range result {
+		b, err := strconv.ParseUint(s[i*2:i*2+2], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex digits '%s'", s[i*2:i*2+2])
+		}
+		result[i] = byte(b)
+	}
+	return result, nil
+}
+
+// checksum adds up the bytes of a record
+func checksum(record []byte) byte {
+	var result byte
+	for _, b := range record {
+		result += b
+	}
+	return result
+}
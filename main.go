@@ -1,29 +1,28 @@
 package main
 
 import (
-	"bufio"
+	"bytes"
+	"flag"
 	"fmt"
-	"io/ioutil"
+	"math"
 	"os"
-	"path/filepath"
-	"strconv"
+	"runtime"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/hculpan/go6502/ascii"
 	"github.com/hculpan/go6502/emulator"
 	"github.com/hculpan/go6502/keyboard"
-	"github.com/hculpan/go6502/resources"
+	"github.com/hculpan/go6502/loader"
+	"github.com/hculpan/go6502/paste"
+	"github.com/hculpan/go6502/runner"
 	"github.com/hculpan/go6502/screen"
 	"github.com/hculpan/go6502/utils"
 	"github.com/sqweek/dialog"
 	"github.com/veandco/go-sdl2/sdl"
 )
 
-const (
-	textCols = 80
-	textRows = 26
-)
-
 const (
 	down = sdl.KEYDOWN
 	up   = sdl.KEYUP
@@ -37,9 +36,23 @@ const (
 	eventResultQuit
 )
 
+// How long the render loop waits for an event before redrawing
+const frameMillis = 16
+
+// How often the clock speed shown in the status bar is updated
+const clockUpdateInterval = time.Second
+
 var status *utils.ComputerStatus
 
-func handleEvent(event sdl.Event, em *emulator.Emulator, scr *screen.Screen, k *keyboard.Keyboard) EventResult {
+// The keys the emulator intercepts rather than sending to the guest
+var shortcuts keyboard.Shortcuts
+
+func init() {
+	// SDL expects all of its calls to come from the main thread
+	runtime.LockOSThread()
+}
+
+func handleEvent(event sdl.Event, em *emulator.Emulator, scr *screen.Screen, k *keyboard.Keyboard, typist *paste.Typist) EventResult {
 	if event != nil {
 		switch event.(type) {
 		case *sdl.QuitEvent:
@@ -73,65 +86,16 @@ func handleEvent(event sdl.Event, em *emulator.Emulator, scr *screen.Screen, k *
 				fmt.Printf("Desktop size: %d, %d\n", mode.W, mode.H)
 			}
 		case *sdl.KeyboardEvent:
-			keycode := sdl.GetKeyFromScancode(event.(*sdl.KeyboardEvent).Keysym.Scancode)
-			if event.(*sdl.KeyboardEvent).Type == sdl.KEYDOWN {
-				switch keycode {
-				case sdl.K_F2:
-					toggleEmulatorOnOff(em, scr)
-					scr.UpdateScreen()
-				case sdl.K_F3:
-					if !status.Running {
-						emulatorOnWithStep(em, scr)
-						scr.EnableDebug(em)
-					}
-					scr.UpdateScreen()
-				case sdl.K_F5:
-					emulatorEnableSingleStep(em, scr)
-				case sdl.K_F6:
-					if status.Running && status.SingleStep {
-						em.NextStep()
-						time.Sleep(100 * time.Millisecond) // Give emulator a little time to advance to next instruction
-					}
-					scr.UpdateScreen()
-				case sdl.K_F7:
-					emulatorDisableSingleStep(em, scr)
-				case sdl.K_F9:
-					filename, err := dialog.File().Filter("TXT files", "txt").Filter("SBIN files", "sbin").Filter("BIN files", "bin").Title("Load ROM File").Load()
-					if err != nil {
-						if strings.Contains(err.Error(), "Cancelled") {
-							break
-						}
-						dialog.Message(fmt.Sprintf("Unable to find %s: %s", filename, err)).Error()
-						return eventResultNone
-					}
-					em.Terminate()
-					em.CPU.Reset()
-					err = loadRAM(filename, em, scr)
-					if err != nil {
-						dialog.Message(fmt.Sprintf("Unable to load %s: %s", filename, err)).Error()
-					}
-					scr.Reset()
-					status.Running = false
-					status.SingleStep = false
-					em.DisableSingleStep()
-					scr.UpdateScreen()
-				case sdl.K_ESCAPE:
-					ok := dialog.Message("Do you wish to exit?").Title("Exit go6502").YesNo()
-					if ok {
-						em.Terminate()
-						return eventResultQuit
-					}
-				default:
-					r := k.ProcessKeyInput(keyboard.NewKeyInputFromEvent(event.(*sdl.KeyboardEvent)))
-					if r != 0 {
-						em.SetKeyWaiting(r)
-					}
-				}
-			} else {
-				r := k.ProcessKeyInput(keyboard.NewKeyInputFromEvent(event.(*sdl.KeyboardEvent)))
-				if r != 0 {
-					em.SetKeyWaiting(r)
-				}
+			input := keyboard.NewKeyInputFromEvent(event.(*sdl.KeyboardEvent))
+			if action, ok := k.Shortcut(input, shortcuts); ok {
+				return handleShortcut(action, em, scr, typist)
+			}
+			for _, r := range k.ProcessKeyInput(input) {
+				em.SetKeyWaiting(r)
+			}
+		case *sdl.TextInputEvent:
+			for _, r := range k.ProcessTextInput(event.(*sdl.TextInputEvent).GetText()) {
+				em.SetKeyWaiting(r)
 			}
 		}
 	}
@@ -139,49 +103,198 @@ func handleEvent(event sdl.Event, em *emulator.Emulator, scr *screen.Screen, k *
 	return eventResultNone
 }
 
+// shortcutActions are the actions that can be given to the
+// keys in -shortcuts, which are carried out by handleShortcut
+var shortcutActions = []string{"debug", "load", "paste", "pause", "power", "quit", "resume", "step"}
+
+// handleShortcut carries out the action for one of the keys
+// the emulator keeps for itself
+func handleShortcut(action string, em *emulator.Emulator, scr *screen.Screen, typist *paste.Typist) EventResult {
+	switch action {
+	case "power":
+		toggleEmulatorOnOff(em, scr)
+		scr.UpdateScreen()
+	case "debug":
+		if !status.Running {
+			emulatorOnWithStep(em, scr)
+			scr.EnableDebug(em)
+		}
+		scr.UpdateScreen()
+	case "pause":
+		emulatorEnableSingleStep(em, scr)
+	case "step":
+		if status.Running && status.SingleStep {
+			em.NextStep()
+			time.Sleep(100 * time.Millisecond) // Give emulator a little time to advance to next instruction
+		}
+		scr.UpdateScreen()
+	case "resume":
+		emulatorDisableSingleStep(em, scr)
+	case "paste":
+		pasteClipboard(typist)
+	case "load":
+		filename, err := imageFileDialog().Title("Load ROM File").Load()
+		if err != nil {
+			if !strings.Contains(err.Error(), "Cancelled") {
+				dialog.Message(fmt.Sprintf("Unable to find %s: %s", filename, err)).Error()
+			}
+			return eventResultNone
+		}
+		em.Terminate()
+		em.CPU.Reset()
+		err = loadRAM(filename, em, scr)
+		if err != nil {
+			dialog.Message(fmt.Sprintf("Unable to load %s: %s", filename, err)).Error()
+		}
+		scr.Reset()
+		status.Running = false
+		status.SingleStep = false
+		em.DisableSingleStep()
+		scr.UpdateScreen()
+	case "quit":
+		ok := dialog.Message("Do you wish to exit?").Title("Exit go6502").YesNo()
+		if ok {
+			em.Terminate()
+			return eventResultQuit
+		}
+	}
+
+	return eventResultNone
+}
+
 func main() {
-	utils.AddBreakpoint(*utils.NewBreakpoint(0x9024, 5))
+	keymapFlag := flag.String("keymap", "", "JSON file with the keyboard layout, e.g. keymaps/uk.json, defaults to US")
+	textInputFlag := flag.Bool("text-input", false, "Type with the characters the host keyboard layout produces, rather than translating keys with the keymap")
+	substituteFlag := flag.String("substitute", "?", "Character sent with -text-input for characters the guest doesn't have, empty to drop them")
+	shortcutsFlag := flag.String("shortcuts", keyboard.DefaultShortcuts, "Keys the window keeps for the emulator, as key=action pairs, e.g. F2=power,Ctrl+Q=quit")
+	stepFlag := flag.Bool("step", false, "Start switched on in single-step, with the debugger showing the first instruction")
+	scaleFlag := flag.Float64("scale", 1, "Window size multiplier, e.g. 2 or 1.5")
+	flags := runner.AddFlags(flag.CommandLine)
+	flag.Usage = runner.Usage(flag.CommandLine, os.Args[0])
+	flag.Parse()
+
+	cfg, err := flags.Config(flag.Args())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if shortcuts, err = parseShortcuts(*shortcutsFlag); err != nil {
+		fmt.Println(err)
+		return
+	}
+	keymap := keyboard.USKeymap()
+	if *keymapFlag != "" {
+		if keymap, err = keyboard.LoadKeymap(*keymapFlag); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	if *scaleFlag <= 0 || *scaleFlag > 8 {
+		fmt.Println("-scale must be more than 0 and at most 8")
+		return
+	}
 
 	status = utils.NewComputerStatus()
-	scr := screen.NewScreen(textCols, textRows, status)
+
+	scr := screen.NewScreen(runner.TextCols, runner.TextRows, status)
+	scr.SetScale(float32(*scaleFlag))
 	if err := scr.Show(); err != nil {
 		fmt.Println(err)
 		return
 	}
 	defer scr.CleanUp()
 	k := keyboard.NewKeyboard()
+	k.SetKeymap(keymap)
+	if err := k.SetTextInput(*textInputFlag, *substituteFlag); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if *textInputFlag {
+		sdl.StartTextInput()
+	} else {
+		sdl.StopTextInput()
+	}
 
-	em := emulator.NewEmulator(scr)
-
-	if err := loadROMBin(em, scr); err != nil {
-		fmt.Println("Failed to load rom:", err)
+	em, name, err := runner.NewEmulator(scr, cfg)
+	if err != nil {
+		fmt.Println(err)
 		return
 	}
+	status.RomFilename = name
+	defer em.Close()
+	if *stepFlag {
+		emulatorOnWithStep(em, scr)
+		scr.EnableDebug(em)
+	}
 
-	defer func() {
-		em.Terminate()
-	}()
+	// The keys wait in the keyboard until the emulator is
+	// switched on and the ROM reads them
+	typist := paste.NewTypist(em, cfg.TypeDelay, cfg.TypeLineDelay)
+	typist.Type(bytes.NewReader(cfg.TypeText))
+	defer typist.Stop()
 
+	lastClockUpdate := time.Now()
 	for {
-		eventResult := handleEvent(sdl.PollEvent(), em, scr, k)
+		eventResult := handleEvent(sdl.WaitEventTimeout(frameMillis), em, scr, k, typist)
 		switch eventResult {
 		case eventResultQuit:
 			return
 		default:
-			if status.Running {
-				em.Step()
-				addr := em.CPU.PC
-				breakpoint, found := utils.FindBreakpoint(addr)
-				fmt.Printf("Breakpoint ptr = %p\n", breakpoint)
-				if found && breakpoint.BreakpointReady() {
-					emulatorEnableSingleStep(em, scr)
-				}
+			if status.Running && !status.SingleStep && em.IsSingleStep() {
+				// The emulator stopped itself at a breakpoint
+				emulatorEnableSingleStep(em, scr)
+			}
+			if time.Since(lastClockUpdate) >= clockUpdateInterval {
+				updateClockStatus(em, scr)
+				lastClockUpdate = time.Now()
 			}
 			scr.DrawScreen()
 		}
 	}
 }
 
+// parseShortcuts reads the -shortcuts flag, checking that
+// the actions are ones the emulator knows about
+func parseShortcuts(s string) (keyboard.Shortcuts, error) {
+	result, err := keyboard.ParseShortcuts(s)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, action := range result.Actions() {
+		i := sort.SearchStrings(shortcutActions, action)
+		if i == len(shortcutActions) || shortcutActions[i] != action {
+			return nil, fmt.Errorf("Unknown shortcut action '%s', expected one of %s", action, strings.Join(shortcutActions, ", "))
+		}
+	}
+	return result, nil
+}
+
+// pasteClipboard types the text on the clipboard into the
+// emulator, or stops if it is already typing
+func pasteClipboard(typist *paste.Typist) {
+	if typist.IsTyping() {
+		typist.Stop()
+		return
+	}
+
+	text, err := sdl.GetClipboardText()
+	if err != nil {
+		dialog.Message(fmt.Sprintf("Unable to read the clipboard: %s", err)).Error()
+		return
+	}
+	typist.TypeString(text)
+}
+
+func updateClockStatus(em *emulator.Emulator, scr *screen.Screen) {
+	mhz := math.Round(em.EffectiveMHz()*100) / 100
+	if mhz != status.ClockMHz {
+		status.ClockMHz = mhz
+		scr.UpdateScreen()
+	}
+}
+
 func toggleEmulatorOnOff(em *emulator.Emulator, scr *screen.Screen) {
 	if status.Running {
 		emulatorOff(em, scr)
@@ -243,161 +356,67 @@ func buildKey(r rune, keyAction uint32) *keyboard.KeyInput {
 }
 
 func sendDownUpKey(r rune, scr *screen.Screen, k *keyboard.Keyboard) {
-	newrune := k.ProcessKeyInput(buildKey(r, down))
-	scr.ProcessRune(newrune)
-	newrune = k.ProcessKeyInput(buildKey(r, up))
-	scr.ProcessRune(newrune)
+	sendDownKey(r, scr, k)
+	sendUpKey(r, scr, k)
 }
 
 func sendDownKey(r rune, scr *screen.Screen, k *keyboard.Keyboard) {
-	newrune := k.ProcessKeyInput(buildKey(r, down))
-	scr.ProcessRune(newrune)
+	for _, newrune := range k.ProcessKeyInput(buildKey(r, down)) {
+		scr.ProcessRune(newrune)
+	}
 }
 
 func sendUpKey(r rune, scr *screen.Screen, k *keyboard.Keyboard) {
-	newrune := k.ProcessKeyInput(buildKey(r, up))
-	scr.ProcessRune(newrune)
+	for _, newrune := range k.ProcessKeyInput(buildKey(r, up)) {
+		scr.ProcessRune(newrune)
+	}
 }
 
 func sendEscSequence(s string, scr *screen.Screen) {
-	scr.ProcessRune(keyboard.Escape)
+	scr.ProcessRune(ascii.Escape)
 	for _, v := range s {
 		scr.ProcessRune(v)
 	}
 }
 
-func loadROMBin(em *emulator.Emulator, scr *screen.Screen) error {
-	// Read rom file
-	rom, err := resources.Asset("resources/rom.bin")
-	if err != nil {
-		return err
-	}
-	if len(rom) > 65024 {
-		return fmt.Errorf("Rom file too large, must be 65024 with origin at 0x0200")
-	} else if len(rom) < 65024 {
-		return fmt.Errorf("Rom file too small, must be 65024 with origin at 0x0200")
-	}
-
-	resetRAM(em)
-	// Write to memory
-	for i := 0; i < 65024; i++ {
-		idx := i + 512
-		writeToEmulatorMemory(em, uint16(idx), rom[i])
-	}
-
-	status.RomFilename = "rom.bin"
-	return nil
-}
-
-func loadBIN(f string, em *emulator.Emulator) error {
-	// Read rom file
-	rom, err := ioutil.ReadFile(f)
-	if err != nil {
-		fmt.Println(err)
-		return err
-	}
-	if len(rom) > 65024 {
-		return fmt.Errorf("Rom file too large, must be 65024 with origin at 0x0200")
-	} else if len(rom) < 65024 {
-		return fmt.Errorf("Rom file too small, must be 65024 with origin at 0x0200")
-	}
-
-	resetRAM(em)
-	// Write to memory
-	for i := 0; i < 65024; i++ {
-		idx := i + 512
-		writeToEmulatorMemory(em, uint16(idx), rom[i])
-	}
-
-	return nil
-}
-
-func loadSBIN(f string, em *emulator.Emulator) error {
-	// Read rom file
-	rom, err := ioutil.ReadFile(f)
+// loadRAM loads an image from the file dialog in place of the
+// current program.  It starts at its entry point if the file
+// gives one, or if it doesn't set the reset vector itself.
+func loadRAM(f string, em *emulator.Emulator, scr *screen.Screen) error {
+	status.RomFilename = ""
+	em.UseResetVector()
+	img, err := loader.Load(f, loader.Options{})
 	if err != nil {
-		fmt.Println(err)
 		return err
 	}
 
-	org := int((uint16(rom[1]) << 8) + uint16(rom[0]))
-	fmt.Printf("org=%04X", org)
-
-	resetRAM(em)
-	// Write to memory
-	for i := 4; i < len(rom); i++ {
-		addr := i + org - 4
-		writeToEmulatorMemory(em, uint16(addr), rom[i])
-	}
-
-	return nil
-}
-
-func loadTXT(f string, em *emulator.Emulator) error {
-	file, err := os.Open(f)
-	if err != nil {
+	em.ClearRAM()
+	if err := runner.WriteImage(em, f, img, false); err != nil {
 		return err
 	}
-	defer file.Close()
-
-	resetRAM(em)
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.Trim(scanner.Text(), " \n\r\t")
-		if len(line) > 0 {
-			addrStr := line[:4]
-			addr, err := strconv.ParseInt(addrStr, 16, 32)
-			if err != nil {
-				return fmt.Errorf("Parsing address '%s': %s", addrStr, err)
-			}
-			data := strings.Split(line[5:], " ")
-			for i, v := range data {
-				d, err := strconv.ParseInt(v, 16, 16)
-				if err != nil {
-					return fmt.Errorf("Parsing data '%s': %s", v, err)
-				}
-				writeToEmulatorMemory(em, uint16(int(addr)+i), byte(d))
-			}
-		}
+	if img.HasEntry && (img.AutoStart || !img.Covers(emulator.ResetVector)) {
+		em.SetResetAddress(img.Entry)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
-	}
+	status.RomFilename = f
 	return nil
 }
 
-func resetRAM(em *emulator.Emulator) {
-	for x := 0; x < 65536; x++ {
-		writeToEmulatorMemory(em, uint16(x), 0)
+// imageFileDialog returns a file dialog for the image formats
+// the loader recognises
+func imageFileDialog() *dialog.FileBuilder {
+	var all []string
+	for _, ext := range loader.Extensions() {
+		all = append(all, strings.TrimPrefix(ext, "."))
 	}
-}
-
-func loadRAM(f string, em *emulator.Emulator, scr *screen.Screen) error {
-	status.RomFilename = ""
-	switch filepath.Ext(f) {
-	case ".bin":
-		if err := loadBIN(f, em); err != nil {
-			return err
+	result := dialog.File().Filter("Images", all...)
+	for _, name := range loader.Names() {
+		format, _ := loader.Lookup(name)
+		var extensions []string
+		for _, ext := range format.Extensions {
+			extensions = append(extensions, strings.TrimPrefix(ext, "."))
 		}
-	case ".sbin":
-		if err := loadSBIN(f, em); err != nil {
-			return err
-		}
-	case ".txt":
-		if err := loadTXT(f, em); err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("Unrecognized file type: %s", filepath.Ext(f))
-	}
-
-	status.RomFilename = f
-	return nil
-}
-
-func writeToEmulatorMemory(em *emulator.Emulator, address uint16, data byte) {
-	if address < 0x8000 || address > 0x83FF {
-		em.WriteMemory(address, data)
+		result = result.Filter(strings.ToUpper(name)+" files", extensions...)
 	}
+	return result
 }
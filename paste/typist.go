@@ -0,0 +1,163 @@
+package paste
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hculpan/go6502/ascii"
+)
+
+// How often to check whether the CPU has read the last key
+const keyPollInterval = time.Millisecond
+
+// KeyReceiver is the part of the emulator the typist
+// sends keys to
+type KeyReceiver interface {
+	SetKeyWaiting(k rune)
+	IsKeyWaiting() bool
+}
+
+// Typist types text into the emulator as if it was typed on the
+// keyboard.  Each key waits for the CPU to read the one before,
+// and then for Delay, or LineDelay after the end of a line to
+// give the ROM time to deal with it.
+type Typist struct {
+	Delay     time.Duration
+	LineDelay time.Duration
+
+	em KeyReceiver
+
+	mu   sync.Mutex
+	stop chan bool // Closed to stop the text being typed
+	done chan bool // Closed when the text has been typed
+}
+
+// NewTypist creates a typist sending keys to em
+func NewTypist(em KeyReceiver, delay, lineDelay time.Duration) *Typist {
+	return &Typist{em: em, Delay: delay, LineDelay: lineDelay}
+}
+
+// Type starts typing the text read from in on its own goroutine,
+// stopping anything that is already being typed.  The channel
+// returned is closed once all the text has been read by the CPU.
+func (t *Typist) Type(in io.Reader) <-chan bool {
+	t.Stop()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.stop = make(chan bool)
+	t.done = make(chan bool)
+	go t.run(bufio.NewReader(in), t.stop, t.done)
+	return t.done
+}
+
+// TypeString starts typing the string, e.g. from the clipboard
+func (t *Typist) TypeString(s string) <-chan bool {
+	return t.Type(strings.NewReader(s))
+}
+
+// IsTyping returns true until all the text has been typed
+func (t *Typist) IsTyping() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.done == nil {
+		return false
+	}
+	select {
+	case <-t.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Stop stops typing.  No more keys are sent once it returns,
+// but it doesn't wait for the typist's goroutine, which may be
+// blocked reading a terminal and is left to finish on its own.
+func (t *Typist) Stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+}
+
+func (t *Typist) run(in *bufio.Reader, stop, done chan bool) {
+	defer close(done)
+
+	afterCR := false
+	for {
+		r, _, err := in.ReadRune()
+		if err != nil {
+			return
+		}
+
+		// Lines can end with CR, LF or CRLF, and CRLF
+		// mustn't press Enter twice
+		delay := t.Delay
+		skip := r == '\n' && afterCR
+		afterCR = r == '\r'
+		switch {
+		case skip:
+			continue
+		case r == '\r' || r == '\n':
+			r = ascii.Enter
+			delay = t.LineDelay
+		case r > 126:
+			// The keyboard sends bytes, so there's no
+			// way to type anything else
+			continue
+		}
+
+		if !t.send(r, stop) {
+			return
+		}
+		for t.em.IsKeyWaiting() {
+			if !t.wait(stop, keyPollInterval) {
+				return
+			}
+		}
+		if !t.wait(stop, delay) {
+			return
+		}
+	}
+}
+
+// send gives the key to the emulator, unless the typist was
+// stopped while it was reading the key.  It holds the lock so
+// that Stop can't return in between.
+func (t *Typist) send(r rune, stop chan bool) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	select {
+	case <-stop:
+		return false
+	default:
+		t.em.SetKeyWaiting(r)
+		return true
+	}
+}
+
+// wait sleeps for the duration, returning false if
+// the typist was stopped in the meantime
+func (t *Typist) wait(stop chan bool, d time.Duration) bool {
+	if d <= 0 {
+		select {
+		case <-stop:
+			return false
+		default:
+			return true
+		}
+	}
+
+	select {
+	case <-stop:
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
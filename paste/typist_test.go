@@ -0,0 +1,71 @@
+package paste
+
+import (
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// keys records the keys typed, taking each straight away
+type keys struct {
+	mu   sync.Mutex
+	keys []rune
+}
+
+func (k *keys) SetKeyWaiting(r rune) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = append(k.keys, r)
+}
+
+func (k *keys) IsKeyWaiting() bool {
+	return false
+}
+
+func (k *keys) String() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return string(k.keys)
+}
+
+func TestTypeString(t *testing.T) {
+	em := &keys{}
+	typist := NewTypist(em, 0, 0)
+	select {
+	case <-typist.TypeString("10 PRINT\r\n20 END\n30 STOP\r\r40 RUN\né"):
+	case <-time.After(time.Second):
+		t.Fatal("Typing didn't finish")
+	}
+	if got := em.String(); got != "10 PRINT\r20 END\r30 STOP\r\r40 RUN\r" {
+		t.Errorf("Typed %q", got)
+	}
+}
+
+// TestStopBlockedReader checks that Stop returns while the typist
+// is waiting to read, as it does when typing from a terminal, and
+// that nothing read afterwards is typed
+func TestStopBlockedReader(t *testing.T) {
+	em := &keys{}
+	typist := NewTypist(em, 0, 0)
+	r, w := io.Pipe()
+	defer w.Close()
+	typist.Type(r)
+
+	stopped := make(chan bool)
+	go func() {
+		typist.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("Stop waited for the reader")
+	}
+
+	go w.Write([]byte("x"))
+	time.Sleep(50 * time.Millisecond)
+	if got := em.String(); got != "" {
+		t.Errorf("Typed %q after Stop", got)
+	}
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hculpan/go6502/emulator"
+	"github.com/hculpan/go6502/utils"
+)
+
+// BreakpointList collects the -break flags.  Each is an address,
+// e.g. $9024, optionally followed by ":N" to stop only every Nth
+// time the CPU gets there.  Several can be given separated by
+// commas.
+type BreakpointList []utils.Breakpoint
+
+// String lists the breakpoints, for the flag package
+func (l *BreakpointList) String() string {
+	var result []string
+	for _, b := range *l {
+		if b.Number > 1 {
+			result = append(result, fmt.Sprintf("$%04X:%d", b.Address, b.Number))
+		} else {
+			result = append(result, fmt.Sprintf("$%04X", b.Address))
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// Set adds the breakpoints from a -break flag
+func (l *BreakpointList) Set(s string) error {
+	for _, part := range strings.Split(s, ",") {
+		b, err := parseBreakpoint(part)
+		if err != nil {
+			return err
+		}
+		*l = append(*l, *b)
+	}
+	return nil
+}
+
+// parseBreakpoint reads a breakpoint, e.g. "$9024" or "$9024:5"
+func parseBreakpoint(s string) (*utils.Breakpoint, error) {
+	parts := strings.SplitN(s, ":", 2)
+	address, err := emulator.ParseNumber(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("Breakpoint '%s': %s", s, err)
+	}
+	if address < 0 || address > 0xFFFF {
+		return nil, fmt.Errorf("Breakpoint '%s': address must be between $0000 and $FFFF", s)
+	}
+
+	times := 0
+	if len(parts) == 2 {
+		if times, err = emulator.ParseNumber(parts[1]); err != nil {
+			return nil, fmt.Errorf("Breakpoint '%s': %s", s, err)
+		}
+		if times < 1 {
+			return nil, fmt.Errorf("Breakpoint '%s': count must be at least 1", s)
+		}
+	}
+	return utils.NewBreakpoint(uint16(address), times), nil
+}
+
+// installBreakpoints adds the breakpoints and makes the emulator
+// stop at them
+func installBreakpoints(em *emulator.Emulator, breakpoints BreakpointList) {
+	for _, b := range breakpoints {
+		utils.AddBreakpoint(b)
+	}
+	em.BreakpointHandler = func(addr uint16) bool {
+		breakpoint, found := utils.FindBreakpoint(addr)
+		return found && breakpoint.BreakpointReady()
+	}
+}
@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hculpan/go6502/emulator"
+	"github.com/hculpan/go6502/loader"
+)
+
+// image is a file loaded into memory from the command line.
+// Raw binaries are given as file.bin@$E000, optionally followed
+// by ",skip=N" to leave out the start of the file and ",length=N"
+// to load only part of it.  PRG files are loaded at the address
+// in their header and o65 files where they were assembled, unless
+// given an address.  ",start" makes the CPU start at the image's
+// entry point rather than the reset vector, and ",format=NAME"
+// overrides the format worked out from the file, e.g. to load
+// a .txt file as raw bytes.
+type image struct {
+	filename string
+	options  loader.Options
+	start    bool
+}
+
+// ImageList collects the images from -load and the command line
+type ImageList []image
+
+// String lists the images, for the flag package
+func (l *ImageList) String() string {
+	names := make([]string, len(*l))
+	for i, image := range *l {
+		names[i] = image.String()
+	}
+	return strings.Join(names, " ")
+}
+
+// Set adds an image from a -load flag
+func (l *ImageList) Set(s string) error {
+	image, err := parseImage(s)
+	if err != nil {
+		return err
+	}
+	*l = append(*l, image)
+	return nil
+}
+
+func (i image) String() string {
+	if !i.options.HasAddress {
+		return i.filename
+	}
+	return fmt.Sprintf("%s@$%04X", i.filename, i.options.Address)
+}
+
+// parseImage reads an image from the command line, e.g.
+// "basic.bin@$E000", "dump.bin@$0800,skip=$100,length=$400",
+// "monitor.prg,start" or "font.dat@$C000,format=raw"
+func parseImage(s string) (image, error) {
+	parts := strings.Split(s, ",")
+	var result image
+	if at := strings.LastIndex(parts[0], "@"); at > 0 {
+		result.filename = parts[0][:at]
+		address, err := emulator.ParseNumber(parts[0][at+1:])
+		if err != nil {
+			return image{}, fmt.Errorf("Image '%s': %s", s, err)
+		}
+		if address < 0 || address > 0xFFFF {
+			return image{}, fmt.Errorf("Image '%s': address must be between $0000 and $FFFF", s)
+		}
+		result.options.Address = address
+		result.options.HasAddress = true
+	} else {
+		result.filename = parts[0]
+	}
+
+	for _, option := range parts[1:] {
+		if strings.TrimSpace(option) == "start" {
+			result.start = true
+			continue
+		}
+
+		kv := strings.SplitN(option, "=", 2)
+		if len(kv) != 2 {
+			return image{}, fmt.Errorf("Image '%s': option '%s' must be start, format=NAME, skip=N or length=N", s, option)
+		}
+		if strings.TrimSpace(kv[0]) == "format" {
+			format, err := loader.Lookup(strings.TrimSpace(kv[1]))
+			if err != nil {
+				return image{}, fmt.Errorf("Image '%s': %s", s, err)
+			}
+			result.options.Format = format.Name
+			continue
+		}
+		n, err := emulator.ParseNumber(kv[1])
+		if err != nil {
+			return image{}, fmt.Errorf("Image '%s': %s", s, err)
+		}
+		if n < 0 {
+			return image{}, fmt.Errorf("Image '%s': %s can't be negative", s, kv[0])
+		}
+
+		switch strings.TrimSpace(kv[0]) {
+		case "skip":
+			result.options.Skip = n
+		case "length":
+			result.options.Length = n
+		default:
+			return image{}, fmt.Errorf("Image '%s': unknown option '%s', expected start, format, skip or length", s, kv[0])
+		}
+	}
+
+	return result, nil
+}
+
+// load reads the image into memory.  Images given an address
+// must land in RAM or ROM; others, such as memory images that
+// cover the I/O area, skip the bytes with nowhere to go.  HEX
+// and S-record files with a start address, and images given
+// ",start", make the CPU start at their entry point.
+func (i image) load(em *emulator.Emulator) error {
+	img, err := loader.Load(i.filename, i.options)
+	if err != nil {
+		return err
+	}
+	if err := WriteImage(em, i.filename, img, i.options.HasAddress); err != nil {
+		return err
+	}
+
+	if img.HasEntry && (img.AutoStart || i.start) {
+		em.SetResetAddress(img.Entry)
+	} else if i.start {
+		return fmt.Errorf("%s: %s images don't have an entry point", i.filename, img.Format)
+	}
+	return nil
+}
+
+// WriteImage copies the image's segments into memory.  With
+// strict set every byte must land in RAM or ROM, otherwise the
+// bytes for I/O and unmapped addresses are skipped, as they are
+// in memory images that cover the I/O area.
+func WriteImage(em *emulator.Emulator, filename string, img *loader.Image, strict bool) error {
+	for _, segment := range img.Segments {
+		for n, b := range segment.Data {
+			address := segment.Address + uint16(n)
+			if !em.LoadMemory(address, b) && strict {
+				return fmt.Errorf("%s: no RAM or ROM at $%04X", filename, address)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package runner sets up the emulator from the command line, for
+// both the window and go6502-headless.  It mustn't import SDL or
+// the dialog package, directly or through another package, so
+// that the headless command starts without a display.
+package runner
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/hculpan/go6502/emulator"
+	"github.com/hculpan/go6502/loader"
+	"github.com/hculpan/go6502/resources"
+)
+
+// The size of the Kabputer's text screen
+const (
+	TextCols = 80
+	TextRows = 26
+)
+
+// Config holds the settings from the command line
+type Config struct {
+	Machine    *emulator.Machine
+	Variant    emulator.Variant
+	ClockSpeed uint64
+	ROMWrites  emulator.ROMWriteMode
+	Images     ImageList
+
+	// Where to stop in single-step, or for the frontends
+	// without a debugger, where to stop running
+	Breakpoints BreakpointList
+
+	// Text to type into the keyboard from -type-file, and
+	// how fast to type it
+	TypeText      []byte
+	TypeDelay     time.Duration
+	TypeLineDelay time.Duration
+}
+
+// Flags are the command line flags every frontend has
+type Flags struct {
+	clock         *string
+	cpu           *string
+	machine       *string
+	romWrites     *string
+	typeFile      *string
+	typeDelay     *time.Duration
+	typeLineDelay *time.Duration
+	config        *Config
+}
+
+// AddFlags adds the common flags to the flag set
+func AddFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{config: &Config{}}
+	f.clock = fs.String("clock", "1MHz", "CPU clock speed, e.g. 1MHz, 2MHz, 500kHz or unlimited")
+	f.cpu = fs.String("cpu", emulator.VariantNMOS.String(), "CPU variant: 6502, 6502-strict or 65C02")
+	f.machine = fs.String("machine", "", "JSON file describing the memory map, defaults to the built-in Kabputer")
+	f.romWrites = fs.String("rom-writes", emulator.ROMWriteIgnore.String(), "What to do when the CPU writes to ROM: ignore, log or break")
+	f.typeFile = fs.String("type-file", "", "Text file to type into the keyboard once the emulator starts, e.g. a BASIC listing")
+	f.typeDelay = fs.Duration("type-delay", 5*time.Millisecond, "Pause after each key the ROM reads when typing a file or pasting")
+	f.typeLineDelay = fs.Duration("type-line-delay", 50*time.Millisecond, "Pause after each line when typing a file or pasting")
+	fs.Var(&f.config.Images, "load", "Image to load, the same as giving it after the options; can be repeated")
+	fs.Var(&f.config.Breakpoints, "break", "Address to stop at in single-step, e.g. $9024, or $9024:5 to stop every 5th time; can be repeated or given as a list")
+	return f
+}
+
+// Config returns the settings from the flags, once they have been
+// parsed, with the images from the rest of the command line
+func (f *Flags) Config(args []string) (*Config, error) {
+	cfg := f.config
+	for _, arg := range args {
+		if err := cfg.Images.Set(arg); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	if cfg.ClockSpeed, err = emulator.ParseClockSpeed(*f.clock); err != nil {
+		return nil, err
+	}
+	if cfg.Variant, err = emulator.ParseVariant(*f.cpu); err != nil {
+		return nil, err
+	}
+	if cfg.ROMWrites, err = emulator.ParseROMWriteMode(*f.romWrites); err != nil {
+		return nil, err
+	}
+	cfg.Machine = emulator.DefaultMachine()
+	if *f.machine != "" {
+		if cfg.Machine, err = emulator.LoadMachine(*f.machine); err != nil {
+			return nil, err
+		}
+	}
+	if *f.typeFile != "" {
+		if cfg.TypeText, err = ioutil.ReadFile(*f.typeFile); err != nil {
+			return nil, err
+		}
+	}
+	cfg.TypeDelay = *f.typeDelay
+	cfg.TypeLineDelay = *f.typeLineDelay
+	return cfg, nil
+}
+
+// Usage describes the command line, for -help
+func Usage(fs *flag.FlagSet, name string) func() {
+	return func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: %s [options] [image ...]\n\n", name)
+		fmt.Fprintf(out, `Images are loaded in order, so later ones overwrite earlier ones.  Without
+any, the machine's ROM is used, or the built-in rom for the Kabputer.  Each
+image is a file, followed by @address for raw binaries or to relocate a .prg
+or .o65 program, then any of these options separated by commas:
+
+  start         Start at the image's entry point rather than the reset vector
+  format=NAME   Load as this format rather than working it out from the file
+  skip=N        Leave out the first N bytes of the file
+  length=N      Load only N bytes
+
+The formats are %s.
+
+For example:
+  %s -machine machines/kabputer.json basic.bin@$E000
+  %s -cpu 65C02 -break $0400 monitor.o65@$0400,start
+
+Options:
+`, strings.Join(loader.Names(), ", "), name, name)
+		fs.PrintDefaults()
+	}
+}
+
+// NewEmulator creates the emulator with the settings from the
+// command line and loads the images, or the rom.  It returns the
+// name of what was loaded, for the status bar.
+func NewEmulator(display emulator.Display, cfg *Config) (*emulator.Emulator, string, error) {
+	em, err := emulator.NewEmulatorFromMachine(display, cfg.Variant, cfg.Machine)
+	if err != nil {
+		return nil, "", err
+	}
+	em.SetClockSpeed(cfg.ClockSpeed)
+	em.SetROMWriteMode(cfg.ROMWrites)
+	installBreakpoints(em, cfg.Breakpoints)
+
+	if len(cfg.Images) > 0 {
+		if err := loadImages(em, cfg.Images); err != nil {
+			return nil, "", err
+		}
+		return em, cfg.Images[0].filename, nil
+	}
+	name, err := loadROMBin(em, cfg.Machine)
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to load rom: %s", err)
+	}
+	return em, name, nil
+}
+
+// loadROMBin loads the built-in rom, unless the machine
+// has its own
+func loadROMBin(em *emulator.Emulator, machine *emulator.Machine) (string, error) {
+	if machine.HasROMImage() {
+		return machine.Name, nil
+	}
+
+	data, err := resources.Asset("resources/rom.bin")
+	if err != nil {
+		return "", err
+	}
+	img, err := loader.LoadData("rom.bin", data, loader.Options{})
+	if err != nil {
+		return "", err
+	}
+
+	em.ClearRAM()
+	if err := WriteImage(em, "rom.bin", img, false); err != nil {
+		return "", err
+	}
+	return "rom.bin", nil
+}
+
+// loadImages loads the images from the command line, in
+// order, so later images overwrite earlier ones
+func loadImages(em *emulator.Emulator, images ImageList) error {
+	em.ClearRAM()
+	em.UseResetVector()
+	for _, image := range images {
+		if err := image.load(em); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckStopped returns an error if the emulator has paused
+// itself, for the frontends without a debugger
+func CheckStopped(em *emulator.Emulator) error {
+	if err := em.Fault(); err != nil {
+		return err
+	}
+	if em.IsSingleStep() {
+		return fmt.Errorf("Stopped at $%04X", em.GetRegisters().PC)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package runner
+
+import (
+	"flag"
+	"go/build"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/hculpan/go6502/loader"
+)
+
+// The packages the headless command must not link, as they need
+// a display, or GTK in dialog's case
+var displayPackages = []string{
+	"github.com/veandco/go-sdl2",
+	"github.com/sqweek/dialog",
+}
+
+// TestNoDisplayImports checks that neither the runner nor the
+// headless command pulls in SDL or dialog through this module's
+// packages
+func TestNoDisplayImports(t *testing.T) {
+	seen := map[string]bool{}
+	var check func(path, from string)
+	check = func(path, from string) {
+		for _, p := range displayPackages {
+			if strings.HasPrefix(path, p) {
+				t.Errorf("%s imports %s", from, path)
+			}
+		}
+		if seen[path] || !strings.HasPrefix(path, "github.com/hculpan/go6502") {
+			return
+		}
+		seen[path] = true
+
+		pkg, err := build.Import(path, ".", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, imp := range pkg.Imports {
+			check(imp, path)
+		}
+	}
+
+	check("github.com/hculpan/go6502/runner", "")
+	check("github.com/hculpan/go6502/cmd/go6502-headless", "")
+}
+
+// nullDisplay throws away what the emulator prints
+type nullDisplay struct{}
+
+func (nullDisplay) ProcessRune(r rune) {}
+func (nullDisplay) IsBusy() bool       { return false }
+func (nullDisplay) Reset()             {}
+
+// TestLoadMemoryImage loads the rom, a memory image that covers
+// the I/O area, from the command line
+func TestLoadMemoryImage(t *testing.T) {
+	rom, err := ioutil.ReadFile("../resources/rom.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"-load", "../resources/rom.bin"},
+		{"../resources/rom.bin"},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags := AddFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := flags.Config(fs.Args())
+		if err != nil {
+			t.Fatal(err)
+		}
+		em, name, err := NewEmulator(nullDisplay{}, cfg)
+		if err != nil {
+			t.Errorf("%v: %s", args, err)
+			continue
+		}
+		if name != "../resources/rom.bin" {
+			t.Errorf("%v: loaded %s", args, name)
+		}
+		for _, address := range []uint16{0x0200, 0xE000, 0xFFFC, 0xFFFD} {
+			expected := rom[address-loader.MemoryImageAddress]
+			if got := em.ReadMemory(address); got != expected {
+				t.Errorf("%v: $%04X is $%02X, expected $%02X", args, address, got, expected)
+			}
+		}
+		em.Close()
+	}
+}
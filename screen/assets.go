@@ -1,4 +1,4 @@
-package utils
+package screen
 
 import (
 	"fmt"
@@ -8,7 +8,7 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/ariejan/i6502"
+	"github.com/hculpan/go6502/emulator"
 	"github.com/hculpan/go6502/utils"
 	"github.com/veandco/go-sdl2/sdl"
 	"github.com/veandco/go-sdl2/ttf"
@@ -19,11 +19,13 @@ const (
 	textRows = 26
 )
 
-// EmulatorInterface provides a de-coupled interface
-// to the emulator to avoid circular package dependencies
+// EmulatorInterface is the part of the emulator the
+// debug screen needs
 type EmulatorInterface interface {
-	GetCPU() *i6502.Cpu
+	GetRegisters() emulator.Registers
 	ReadMemory(address uint16) uint8
+	Disassemble(address uint16) (string, uint16)
+	GetInterrupts() []emulator.InterruptState
 }
 
 type codeLine struct {
@@ -48,13 +50,16 @@ type DebugScreen struct {
 	em EmulatorInterface
 
 	lastPC               uint16
+	lastInterrupts       string
 	lastDebugTexture     *sdl.Texture
 	debugHeaderTexure    *sdl.Texture
 	lastDebugCodeTexture *sdl.Texture
 	lastStackTexture     *sdl.Texture
+	lastInterruptTexture *sdl.Texture
 
-	status    *utils.ComputerStatus
-	debugCode []codeLine
+	status       *utils.ComputerStatus
+	debugCode    []codeLine
+	disassembled bool
 
 	Active bool
 }
@@ -70,14 +75,14 @@ func NewDebugScreen(parent *Screen) *DebugScreen {
 }
 
 func (s *DebugScreen) initializeFonts() error {
-	font, fontmetrics, err := utils.LoadFont("UbuntuMono-B.ttf")
+	font, fontmetrics, err := LoadFont("UbuntuMono-B.ttf")
 	if err != nil {
 		return fmt.Errorf("Error loading font UbuntuMono-B: %v", err)
 	}
 	s.font = font
 	s.fontmetrics = fontmetrics
 
-	s.charWidth, s.charHeight = utils.GetCharacterMetrics(s.fontmetrics)
+	s.charWidth, s.charHeight = GetCharacterMetrics(s.fontmetrics)
 
 	return nil
 }
@@ -94,10 +99,10 @@ func (s *DebugScreen) createDebugWindow() error {
 
 	window, err := sdl.CreateWindow(
 		"Debug Monitor",
-		x-s.screenWidth,
+		x-s.parent.scaled(s.screenWidth),
 		y,
-		s.screenWidth,
-		s.screenHeight,
+		s.parent.scaled(s.screenWidth),
+		s.parent.scaled(s.screenHeight),
 		sdl.WINDOW_ALLOW_HIGHDPI|sdl.WINDOW_HIDDEN,
 	)
 	if err != nil {
@@ -110,6 +115,9 @@ func (s *DebugScreen) createDebugWindow() error {
 		return err
 	}
 	s.renderer = renderer
+	if err := s.renderer.SetScale(s.parent.scale, s.parent.scale); err != nil {
+		return err
+	}
 
 	// This is a kludge.  I force it to load all the printable ASCII
 	// characters in the font, and this seems to cause it to print
@@ -120,24 +128,24 @@ func (s *DebugScreen) createDebugWindow() error {
 	return nil
 }
 
-func (s *DebugScreen) createDebugHeaderTexture(renderer *sdl.Renderer, c *i6502.Cpu) (*sdl.Texture, error) {
+func (s *DebugScreen) createDebugHeaderTexture(renderer *sdl.Renderer, c emulator.Registers) (*sdl.Texture, error) {
 	if s.debugHeaderTexure != nil {
 		s.debugHeaderTexure.Destroy()
 	}
 	msg := fmt.Sprintf("           A       X       Y      FLAGS:NVxxDIZC      SP")
-	texture, err := utils.CreateTexture(msg, s.parent.foreground, s.font, renderer)
+	texture, err := CreateTexture(msg, s.parent.foreground, s.font, renderer)
 	if err != nil {
 		return nil, fmt.Errorf("Error formatting header: %v", err)
 	}
 	return texture, nil
 }
 
-func (s *DebugScreen) createDebugTexture(renderer *sdl.Renderer, c *i6502.Cpu) (*sdl.Texture, error) {
+func (s *DebugScreen) createDebugTexture(renderer *sdl.Renderer, c emulator.Registers) (*sdl.Texture, error) {
 	if s.lastDebugTexture != nil {
 		s.lastDebugTexture.Destroy()
 	}
 	msg := fmt.Sprintf("          %02X      %02X      %02X            %08b      %02X", c.A, c.X, c.Y, c.P, c.SP)
-	texture, err := utils.CreateTexture(msg, s.parent.foreground, s.font, renderer)
+	texture, err := CreateTexture(msg, s.parent.foreground, s.font, renderer)
 	if err != nil {
 		return nil, fmt.Errorf("Creating debug texture: %v", err)
 	}
@@ -145,6 +153,33 @@ func (s *DebugScreen) createDebugTexture(renderer *sdl.Renderer, c *i6502.Cpu) (
 	return texture, nil
 }
 
+// createInterruptTexture shows which devices are pulling
+// each of the interrupt lines low
+func (s *DebugScreen) createInterruptTexture(renderer *sdl.Renderer, interrupts []emulator.InterruptState) (*sdl.Texture, error) {
+	if s.lastInterruptTexture != nil {
+		s.lastInterruptTexture.Destroy()
+	}
+
+	devices := map[emulator.InterruptLine][]string{}
+	for _, i := range interrupts {
+		devices[i.Line] = append(devices[i.Line], i.Device)
+	}
+	msg := ""
+	for _, line := range []emulator.InterruptLine{emulator.LineIRQ, emulator.LineNMI} {
+		asserted := "-"
+		if len(devices[line]) > 0 {
+			asserted = strings.Join(devices[line], ", ")
+		}
+		msg += fmt.Sprintf("  %s: %-30s", line, asserted)
+	}
+
+	texture, err := CreateTexture(msg, s.parent.foreground, s.font, renderer)
+	if err != nil {
+		return nil, fmt.Errorf("Creating interrupt texture: %v", err)
+	}
+	return texture, nil
+}
+
 func (s *DebugScreen) createDebugCodeTexture(renderer *sdl.Renderer) (*sdl.Texture, error) {
 	if s.lastDebugCodeTexture != nil {
 		s.lastDebugCodeTexture.Destroy()
@@ -170,13 +205,19 @@ func (s *DebugScreen) createStackTexture(renderer *sdl.Renderer) (*sdl.Texture,
 }
 
 func (s *DebugScreen) creatureAllTextures(renderer *sdl.Renderer) error {
-	texture, err := s.createDebugTexture(renderer, s.em.GetCPU())
+	texture, err := s.createDebugTexture(renderer, s.em.GetRegisters())
 	if err != nil {
 		return err
 	}
 	s.lastDebugTexture = texture
 
-	texture, err = s.createDebugHeaderTexture(renderer, s.em.GetCPU())
+	texture, err = s.createInterruptTexture(renderer, s.em.GetInterrupts())
+	if err != nil {
+		return err
+	}
+	s.lastInterruptTexture = texture
+
+	texture, err = s.createDebugHeaderTexture(renderer, s.em.GetRegisters())
 	if err != nil {
 		return err
 	}
@@ -193,7 +234,10 @@ func (s *DebugScreen) creatureAllTextures(renderer *sdl.Renderer) error {
 	renderer.SetDrawColor(32, 32, 32, 255)
 	renderer.Clear()
 	renderer.SetDrawColor(s.parent.foreground.R, s.parent.foreground.B, s.parent.foreground.G, s.parent.foreground.A)
-	s.DrawCodeLines(renderer, s.em.GetCPU().PC)
+	if s.disassembled {
+		s.disassemble(s.em.GetRegisters().PC)
+	}
+	s.DrawCodeLines(renderer, s.em.GetRegisters().PC)
 	renderer.SetRenderTarget(lastTarget)
 
 	texture, err = s.createStackTexture(renderer)
@@ -210,17 +254,18 @@ func (s *DebugScreen) creatureAllTextures(renderer *sdl.Renderer) error {
 	s.DrawStack(renderer, s.em)
 	renderer.SetRenderTarget(lastTarget)
 
-	s.lastPC = s.em.GetCPU().PC
+	s.lastPC = s.em.GetRegisters().PC
+	s.lastInterrupts = fmt.Sprint(s.em.GetInterrupts())
 	return nil
 }
 
 // DrawStack draws the stack info to the specified renderer
 func (s *DebugScreen) DrawStack(renderer *sdl.Renderer, em EmulatorInterface) error {
-	segmentAddress := em.GetCPU().SP
+	segmentAddress := em.GetRegisters().SP
 	for i := 0; i < 21; i++ {
 		addr := uint16(segmentAddress) + 0x0100
 		msg := fmt.Sprintf("%04X:%02X", addr, em.ReadMemory(addr))
-		t, err := utils.CreateTexture(msg, s.parent.foreground, s.font, renderer)
+		t, err := CreateTexture(msg, s.parent.foreground, s.font, renderer)
 		if err != nil {
 			return fmt.Errorf("Error drawing stack: %v", err)
 		}
@@ -286,6 +331,17 @@ func (s *DebugScreen) displayAllTextures(renderer *sdl.Renderer) error {
 		&sdl.Rect{X: s.charWidth * 52, Y: 75, W: w, H: h},
 	)
 
+	_, _, w, h, err = s.lastInterruptTexture.Query()
+	if err != nil {
+		return fmt.Errorf("Unable to query interrupt texture: %v", err)
+	}
+
+	renderer.Copy(
+		s.lastInterruptTexture,
+		&sdl.Rect{X: 0, Y: 0, W: w, H: h},
+		&sdl.Rect{X: 0, Y: 75 + s.charHeight*22, W: w, H: h},
+	)
+
 	return nil
 }
 
@@ -294,7 +350,8 @@ func (s *DebugScreen) displayDebugInfo(renderer *sdl.Renderer) error {
 		return nil
 	}
 
-	if s.lastDebugTexture == nil || s.lastDebugCodeTexture == nil || s.lastPC != s.em.GetCPU().PC {
+	interrupts := fmt.Sprint(s.em.GetInterrupts())
+	if s.lastDebugTexture == nil || s.lastDebugCodeTexture == nil || s.lastPC != s.em.GetRegisters().PC || s.lastInterrupts != interrupts {
 		if err := s.creatureAllTextures(renderer); err != nil {
 			return err
 		}
@@ -331,7 +388,7 @@ func (s *DebugScreen) DrawCodeLines(renderer *sdl.Renderer, PC uint16) error {
 			if idx < startIndex || idx > endIndex || len(s.debugCode[idx].line) == 0 {
 				continue
 			}
-			t, err := utils.CreateTexture(s.debugCode[idx].line, s.parent.foreground, s.font, renderer)
+			t, err := CreateTexture(s.debugCode[idx].line, s.parent.foreground, s.font, renderer)
 			if err != nil {
 				return fmt.Errorf("Error rendering debug code lines: %v", err)
 			}
@@ -382,7 +439,7 @@ func (s *DebugScreen) DrawScreen() {
 	if err := s.displayDebugInfo(renderer); err != nil {
 		panic(err)
 	}
-	//	s.DrawCodeLines(renderer, s.em.GetCPU().PC)
+	//	s.DrawCodeLines(renderer, s.em.GetRegisters().PC)
 
 	renderer.Present()
 }
@@ -402,6 +459,9 @@ func (s *DebugScreen) CleanUp() {
 	if s.debugHeaderTexure != nil {
 		s.debugHeaderTexure.Destroy()
 	}
+	if s.lastInterruptTexture != nil {
+		s.lastInterruptTexture.Destroy()
+	}
 
 	s.font.Close()
 	if err := s.renderer.Destroy(); err != nil {
@@ -413,8 +473,25 @@ func (s *DebugScreen) CleanUp() {
 	s.window = nil
 }
 
+// disassemble fills the code lines from the emulator's memory,
+// starting at the specified address.  This is used when there
+// is no debug_code file for the rom.
+func (s *DebugScreen) disassemble(address uint16) {
+	s.debugCode = []codeLine{}
+	for i := 0; i < 11; i++ {
+		line, next := s.em.Disassemble(address)
+		bytes := ""
+		for a := address; a != next; a++ {
+			bytes += fmt.Sprintf("%02X ", s.em.ReadMemory(a))
+		}
+		s.debugCode = append(s.debugCode, codeLine{address: address, line: fmt.Sprintf("$%04X  %-9s  %s", address, bytes, line)})
+		address = next
+	}
+}
+
 func (s *DebugScreen) loadDebugCode() {
 	s.debugCode = []codeLine{}
+	s.disassembled = true
 	if len(s.status.RomFilename) > 0 {
 		f := filepath.Base(s.status.RomFilename)
 		f = strings.Split(f, ".")[0]
@@ -440,6 +517,7 @@ func (s *DebugScreen) loadDebugCode() {
 				s.debugCode = append(s.debugCode, codeLine{address: uint16(addr), line: line})
 			}
 		}
+		s.disassembled = len(s.debugCode) == 0
 
 		readFile.Close()
 	}
@@ -466,7 +544,7 @@ func (s *DebugScreen) Hide() {
 
 func (s *DebugScreen) initializeSymbols() error {
 	for x := 32; x < 127; x++ {
-		t, err := utils.CreateTexture(string(rune(x)), s.parent.foreground, s.font, s.renderer)
+		t, err := CreateTexture(string(rune(x)), s.parent.foreground, s.font, s.renderer)
 		if err != nil {
 			return err
 		}
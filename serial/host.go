@@ -0,0 +1,168 @@
+package serial
+
+import (
+	"io"
+	"log"
+	"net"
+	"sync"
+)
+
+// Number of bytes buffered in each direction
+const bufferSize = 4096
+
+// Host is the host end of an emulated serial port.  Bytes from
+// whatever is connected, a TCP client or a program using the
+// PTY, arrive on Receive, and bytes given to Send are passed on
+// to it.
+type Host struct {
+	// Name is where to connect to the port, e.g. the
+	// address being listened on or the PTY's device
+	Name string
+
+	rx chan byte
+	tx chan byte
+
+	mu   sync.Mutex
+	conn io.ReadWriteCloser
+	done chan bool
+
+	closeOnce sync.Once
+}
+
+func newHost(name string) *Host {
+	result := &Host{
+		Name: name,
+		rx:   make(chan byte, bufferSize),
+		tx:   make(chan byte, bufferSize),
+		done: make(chan bool),
+	}
+	go result.write()
+	return result
+}
+
+// Receive returns the bytes sent from the host
+func (h *Host) Receive() <-chan byte {
+	return h.rx
+}
+
+// Send passes the byte on to the host.  It is thrown away if
+// nothing is connected, or if the host has fallen behind and the
+// buffer is full, as Send is called with the emulator locked.
+func (h *Host) Send(b byte) {
+	if !h.Connected() {
+		return
+	}
+	select {
+	case h.tx <- b:
+	default:
+	}
+}
+
+// Connected returns true if something is connected to the
+// host end of the port
+func (h *Host) Connected() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.conn != nil
+}
+
+// Close disconnects the host and stops accepting connections,
+// closing the listener or the PTY.  It can be called more than once.
+func (h *Host) Close() error {
+	h.closeOnce.Do(func() { close(h.done) })
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn != nil {
+		return h.conn.Close()
+	}
+	return nil
+}
+
+// ListenTCP accepts connections, one at a time, on the address,
+// e.g. "127.0.0.1:6551"
+func ListenTCP(address string) (*Host, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	result := newHost(listener.Addr().String())
+	go result.accept(listener)
+	return result, nil
+}
+
+func (h *Host) accept(listener net.Listener) {
+	go func() {
+		<-h.done
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		h.connect(conn)
+		h.read(conn)
+	}
+}
+
+// connect starts using conn, dropping anything already connected
+func (h *Host) connect(conn io.ReadWriteCloser) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn != nil {
+		h.conn.Close()
+	}
+	h.conn = conn
+}
+
+func (h *Host) disconnect(conn io.ReadWriteCloser) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conn == conn {
+		h.conn.Close()
+		h.conn = nil
+	}
+}
+
+// read passes the bytes from conn on to the receiver until the
+// connection is closed
+func (h *Host) read(conn io.ReadWriteCloser) {
+	defer h.disconnect(conn)
+
+	buf := make([]byte, 256)
+	for {
+		n, err := conn.Read(buf)
+		for _, b := range buf[:n] {
+			select {
+			case h.rx <- b:
+			case <-h.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (h *Host) write() {
+	for {
+		select {
+		case b := <-h.tx:
+			h.mu.Lock()
+			conn := h.conn
+			h.mu.Unlock()
+			if conn == nil {
+				continue
+			}
+			if _, err := conn.Write([]byte{b}); err != nil {
+				log.Printf("Serial port %s: %s", h.Name, err)
+				h.disconnect(conn)
+			}
+		case <-h.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package serial
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// TestSendFull checks that Send doesn't block the emulator once
+// the buffer fills up because the other end isn't reading
+func TestSendFull(t *testing.T) {
+	h := newHost("test")
+	conn, other := net.Pipe()
+	defer other.Close()
+	h.connect(conn)
+	defer h.Close()
+
+	sent := make(chan bool)
+	go func() {
+		for i := 0; i < bufferSize*2; i++ {
+			h.Send(byte(i))
+		}
+		close(sent)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("Send blocked with the buffer full")
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	h := newHost("test")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
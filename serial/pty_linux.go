@@ -0,0 +1,61 @@
+package serial
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+
+	"github.com/hculpan/go6502/termios"
+)
+
+// OpenPTY creates a pseudo-terminal, whose device, e.g.
+// /dev/pts/3, is in the Name for a terminal program to open
+func OpenPTY() (*Host, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, unsafe.Pointer(&unlock)); err != nil {
+		master.Close()
+		return nil, err
+	}
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, unsafe.Pointer(&n)); err != nil {
+		master.Close()
+		return nil, err
+	}
+	name := fmt.Sprintf("/dev/pts/%d", n)
+
+	// Keep the slave open, so that reading the master doesn't fail
+	// while no program has it open, and pass the bytes through as
+	// they are rather than as lines
+	slave, err := os.OpenFile(name, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	if _, err := termios.MakeRaw(int(slave.Fd())); err != nil {
+		slave.Close()
+		master.Close()
+		return nil, err
+	}
+
+	result := newHost(name)
+	result.connect(master)
+	go func() {
+		result.read(master)
+		slave.Close()
+	}()
+	return result, nil
+}
+
+func ioctl(f *os.File, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
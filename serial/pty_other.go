@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package serial
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// OpenPTY is only supported on Linux
+func OpenPTY() (*Host, error) {
+	return nil, fmt.Errorf("Serial ports on a PTY are not supported on %s", runtime.GOOS)
+}
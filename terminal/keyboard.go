@@ -0,0 +1,52 @@
+package terminal
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/hculpan/go6502/ascii"
+)
+
+// QuitKey is Ctrl-], which ends the terminal session, as
+// Ctrl-C is passed through to the emulator in raw mode
+const QuitKey = 0x1D
+
+// Keyboard reads the keys typed in the terminal, which must
+// be in raw mode
+type Keyboard struct {
+	in   *bufio.Reader
+	keys chan rune
+}
+
+// NewKeyboard starts reading keys from in, normally os.Stdin
+func NewKeyboard(in io.Reader) *Keyboard {
+	result := &Keyboard{in: bufio.NewReader(in), keys: make(chan rune)}
+	go result.run()
+	return result
+}
+
+// Keys returns the keys as they are typed, translated to the
+// runes used by the emulator.  It is closed when the input ends.
+func (k *Keyboard) Keys() <-chan rune {
+	return k.keys
+}
+
+func (k *Keyboard) run() {
+	defer close(k.keys)
+
+	for {
+		b, err := k.in.ReadByte()
+		if err != nil {
+			return
+		}
+
+		r := rune(b)
+		switch r {
+		case ascii.Delete: // What most terminals send for backspace
+			r = ascii.Backspace
+		case '\n':
+			r = ascii.Enter
+		}
+		k.keys <- r
+	}
+}
@@ -0,0 +1,84 @@
+package terminal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/hculpan/go6502/emulator"
+	"github.com/hculpan/go6502/video"
+)
+
+// Screen shows the text screen in the host terminal, using
+// ANSI escape codes to move the cursor to the cells that
+// have changed
+type Screen struct {
+	*video.VideoRAM
+
+	out      io.Writer
+	previous []rune
+	status   string
+}
+
+var _ emulator.Display = (*Screen)(nil)
+
+// NewScreen creates a screen of the specified size that
+// writes to out, normally os.Stdout.  The status line is
+// shown underneath it.
+func NewScreen(out io.Writer, cols int, rows int, status string) *Screen {
+	return &Screen{VideoRAM: video.NewVideoRAM(cols, rows), out: out, status: status}
+}
+
+// Show clears the terminal and draws the whole screen
+func (s *Screen) Show() error {
+	s.previous = nil
+	return s.DrawScreen()
+}
+
+// DrawScreen writes the cells that have changed since the
+// last time it was called, then moves the cursor
+func (s *Screen) DrawScreen() error {
+	var buf bytes.Buffer
+	s.Draw(s.previous == nil, func(cells []rune, cursor *video.CursorPos) {
+		cols, rows := s.GetTextSize()
+		if s.previous == nil {
+			// Clear the terminal and make sure every cell is drawn
+			buf.WriteString("\x1b[0m\x1b[H\x1b[2J")
+			s.previous = make([]rune, len(cells))
+			for i := range s.previous {
+				s.previous[i] = -1
+			}
+			fmt.Fprintf(&buf, "\x1b[%d;1H\x1b[7m%-*s\x1b[0m", rows+1, cols, s.status)
+		}
+
+		next := -1
+		for i, r := range cells {
+			if r == s.previous[i] {
+				continue
+			}
+			if i != next {
+				fmt.Fprintf(&buf, "\x1b[%d;%dH", i/cols+1, i%cols+1)
+			}
+			if r == 0 {
+				r = ' '
+			}
+			buf.WriteRune(r)
+			s.previous[i] = cells[i]
+			next = i + 1
+		}
+
+		fmt.Fprintf(&buf, "\x1b[%d;%dH", cursor.Y+1, cursor.X+1)
+	})
+
+	if buf.Len() == 0 {
+		return nil
+	}
+	_, err := s.out.Write(buf.Bytes())
+	return err
+}
+
+// CleanUp leaves the terminal cursor below the screen
+func (s *Screen) CleanUp() {
+	_, rows := s.GetTextSize()
+	fmt.Fprintf(s.out, "\x1b[0m\x1b[%d;1H\r\n", rows+2)
+}
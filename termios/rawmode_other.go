@@ -0,0 +1,22 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+// +build !linux,!darwin,!dragonfly,!freebsd,!netbsd,!openbsd
+
+package termios
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// State holds the terminal settings to go back to
+type State struct{}
+
+// MakeRaw is not supported on this platform
+func MakeRaw(fd int) (*State, error) {
+	return nil, fmt.Errorf("Terminal mode is not supported on %s", runtime.GOOS)
+}
+
+// Restore is not supported on this platform
+func Restore(fd int, state *State) error {
+	return nil
+}
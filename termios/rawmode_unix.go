@@ -0,0 +1,51 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+// +build linux darwin dragonfly freebsd netbsd openbsd
+
+package termios
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// State holds the terminal settings to go back to
+type State struct {
+	termios syscall.Termios
+}
+
+// MakeRaw puts the terminal in raw mode, so that keys are
+// passed through as they are typed without being echoed or
+// turned into signals, and returns the previous state
+func MakeRaw(fd int) (*State, error) {
+	var old syscall.Termios
+	if err := ioctl(fd, ioctlGetTermios, &old); err != nil {
+		return nil, err
+	}
+
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Oflag &^= syscall.OPOST
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+
+	return &State{termios: old}, nil
+}
+
+// Restore puts the terminal back the way it was
+func Restore(fd int, state *State) error {
+	return ioctl(fd, ioctlSetTermios, &state.termios)
+}
+
+func ioctl(fd int, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -1,7 +1,5 @@
 package utils
 
-import "fmt"
-
 // Breakpoint is an address that we want to
 // drop into single-step when the emulator gets
 // to this point
@@ -30,10 +28,8 @@ func NewBreakpoint(address uint16, NumberTimes int) *Breakpoint {
 // FindBreakpoint returns a breakpoint for the specified
 // address, if one exists
 func FindBreakpoint(addr uint16) (*Breakpoint, bool) {
-	for _, v := range Breakpoints {
-		if v.Address == addr {
-			return &v, true
-		}
+	if idx := findBreakpointIndex(addr); idx >= 0 {
+		return &Breakpoints[idx], true
 	}
 
 	return nil, false
@@ -73,9 +69,6 @@ func RemoveBreakpoint(b Breakpoint) {
 // will be reset
 func (b *Breakpoint) BreakpointReady() bool {
 	b.count--
-
-	fmt.Printf("BreakpointReady called: %d\n", b.count)
-
 	if b.count <= 0 {
 		b.count = b.Number
 		return true
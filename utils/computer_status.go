@@ -9,6 +9,7 @@ type ComputerStatus struct {
 	Running     bool
 	RomFilename string
 	SingleStep  bool
+	ClockMHz    float64
 }
 
 // NewComputerStatus returns a new emulator status
@@ -32,13 +33,19 @@ func (e ComputerStatus) IsSingleStep() bool {
 	return e.SingleStep
 }
 
+// GetClockMHz returns the effective speed of the emulator
+func (e ComputerStatus) GetClockMHz() float64 {
+	return e.ClockMHz
+}
+
 // Copy creates a new copy of the computer status
 func (e ComputerStatus) Copy() *ComputerStatus {
-	return &ComputerStatus{Running: e.Running, SingleStep: e.SingleStep, RomFilename: e.RomFilename}
+	return &ComputerStatus{Running: e.Running, SingleStep: e.SingleStep, RomFilename: e.RomFilename, ClockMHz: e.ClockMHz}
 }
 
 // Equals returns true if and only if all the members of the provided
 // ComputerStatus all match this object
 func (e ComputerStatus) Equals(e2 ComputerStatus) bool {
-	return e.Running == e2.Running && e.SingleStep == e2.SingleStep && e.RomFilename == e2.RomFilename
+	return e.Running == e2.Running && e.SingleStep == e2.SingleStep && e.RomFilename == e2.RomFilename &&
+		e.ClockMHz == e2.ClockMHz
 }
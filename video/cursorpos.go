@@ -1,4 +1,4 @@
-package screen
+package video
 
 // CursorPos represents the position of the cursor on the screen
 // It also manages the extents of the screen, so that the cursor
@@ -0,0 +1,108 @@
+package video
+
+import "regexp"
+
+type escapeCode struct {
+	Code    string
+	Matcher *regexp.Regexp
+	Action  func(v *VideoRAM, escapeSequence string)
+}
+
+// escapeCodes are the sequences understood after an Escape
+var escapeCodes = []escapeCode{
+	{
+		Code:    "[D",
+		Matcher: regexp.MustCompile(`\[D`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			v.scrollUp()
+			v.cursor.ClearScroll()
+		},
+	},
+	{
+		Code:    "[M",
+		Matcher: regexp.MustCompile(`\[M`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			v.scrollDown()
+			v.cursor.ClearScroll()
+		},
+	},
+	{
+		Code:    "[H",
+		Matcher: regexp.MustCompile(`\[H`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			v.cursor.X = 0
+			v.cursor.Y = 0
+			v.cursor.ClearScroll()
+		},
+	},
+	{
+		Code:    "[2J",
+		Matcher: regexp.MustCompile(`\[2J`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			v.clear()
+		},
+	},
+	{
+		Code:    "[<n>A",
+		Matcher: regexp.MustCompile(`\[[0-9]+A`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			n := findFirstNumber(escapeSequence, 0)
+			for i := 0; i < n; i++ {
+				v.cursor.Y--
+			}
+			if v.cursor.Y < 0 {
+				v.cursor.Y = 0
+			}
+		},
+	},
+	{
+		Code:    "[<n>B",
+		Matcher: regexp.MustCompile(`\[[0-9]+B`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			n := findFirstNumber(escapeSequence, 0)
+			for i := 0; i < n; i++ {
+				v.cursor.Y++
+			}
+			if v.cursor.Y >= v.textRows {
+				v.cursor.Y = v.textRows - 1
+			}
+		},
+	},
+	{
+		Code:    "[<n>C",
+		Matcher: regexp.MustCompile(`\[[0-9]+C`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			n := findFirstNumber(escapeSequence, 0)
+			for i := 0; i < n; i++ {
+				v.cursor.X++
+			}
+			if v.cursor.X >= v.textCols {
+				v.cursor.X = v.textCols - 1
+			}
+		},
+	},
+	{
+		Code:    "[<n>D",
+		Matcher: regexp.MustCompile(`\[[0-9]+D`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			n := findFirstNumber(escapeSequence, 0)
+			for i := 0; i < n; i++ {
+				v.cursor.X--
+			}
+			if v.cursor.X < 0 {
+				v.cursor.X = 0
+			}
+		},
+	},
+	{
+		Code:    "[<n>;<n>H",
+		Matcher: regexp.MustCompile(`\[[0-9]+;[0-9]+H`),
+		Action: func(v *VideoRAM, escapeSequence string) {
+			n1, n2 := findTwoNumbers(escapeSequence, -1)
+			if n1 >= 0 && n1 < v.textCols && n2 >= 0 && n2 < v.textRows {
+				v.cursor.X = n1
+				v.cursor.Y = n2
+			}
+		},
+	},
+}
@@ -0,0 +1,83 @@
+package video
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hculpan/go6502/ascii"
+)
+
+// write sends the string to the screen, with '^' standing
+// for Escape and '|' for Enter
+func write(v *VideoRAM, s string) {
+	for _, r := range s {
+		switch r {
+		case '^':
+			r = ascii.Escape
+		case '|':
+			r = ascii.Enter
+		}
+		v.ProcessRune(r)
+	}
+}
+
+// rows returns the screen's rows, with '.' for empty cells
+func rows(v *VideoRAM) []string {
+	var result []string
+	v.Draw(true, func(cells []rune, cursor *CursorPos) {
+		for y := 0; y < v.textRows; y++ {
+			row := make([]rune, v.textCols)
+			for x := range row {
+				row[x] = cells[v.indexOf(x, y)]
+				if row[x] == 0 {
+					row[x] = '.'
+				}
+			}
+			result = append(result, string(row))
+		}
+	})
+	return result
+}
+
+func TestEscapeCodes(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		rows   string // Rows separated by '/'
+		x, y   int
+	}{
+		{"text", "ab|cd", "ab..../cd..../....../......", 2, 1},
+
+		// Cursor movements, which need a count
+		{"cursor up", "ab||c^[1A", "ab..../....../c...../......", 1, 1},
+		{"cursor down", "ab^[1B", "ab..../....../....../......", 2, 1},
+		{"cursor right", "ab^[1C", "ab..../....../....../......", 3, 0},
+		{"cursor left", "abc^[1D", "abc.../....../....../......", 2, 0},
+		{"cursor left overwrites", "abc^[1Dx", "abx.../....../....../......", 3, 0},
+		{"cursor left 3", "abcde^[3D", "abcde./....../....../......", 2, 0},
+		{"cursor left 0", "abc^[0D", "abc.../....../....../......", 3, 0},
+		{"cursor left at edge", "^[9D", "....../....../....../......", 0, 0},
+		{"cursor down 9", "^[9B", "....../....../....../......", 0, 3},
+		{"cursor right 9", "^[9C", "....../....../....../......", 5, 0},
+
+		// Scrolling
+		{"scroll up", "ab|cd|ef^[D", "cd..../ef..../....../......", 2, 2},
+		{"scroll down", "ab|cd^[M", "....../ab..../cd..../......", 2, 1},
+
+		// Other sequences
+		{"home", "ab|cd^[H", "ab..../cd..../....../......", 0, 0},
+		{"position", "^[3;2Hx", "....../....../...x../......", 4, 2},
+		{"clear", "ab|cd^[2J", "....../....../....../......", 2, 1},
+	}
+
+	for _, test := range tests {
+		v := NewVideoRAM(6, 4)
+		write(v, test.output)
+		if got := strings.Join(rows(v), "/"); got != test.rows {
+			t.Errorf("%s: screen is %s, expected %s", test.name, got, test.rows)
+		}
+		if v.cursor.X != test.x || v.cursor.Y != test.y {
+			t.Errorf("%s: cursor at %d,%d, expected %d,%d", test.name, v.cursor.X, v.cursor.Y, test.x, test.y)
+		}
+	}
+}
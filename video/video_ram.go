@@ -0,0 +1,186 @@
+package video
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hculpan/go6502/ascii"
+)
+
+const escapeMaxLength = 10
+
+var numMatcher *regexp.Regexp = regexp.MustCompile("[0-9]+")
+
+// VideoRAM holds the characters on the text screen and the
+// cursor position.  It interprets the characters written to
+// the screen interface, including the escape sequences, and
+// leaves it to the frontends to draw the result.
+type VideoRAM struct {
+	cursor *CursorPos
+
+	textCols int
+	textRows int
+
+	busy bool
+
+	escapeMode     bool
+	escapeSequence string
+
+	// mu guards the video state, which is written by the
+	// emulator goroutine and read by the render loop
+	mu sync.Mutex
+
+	cells []rune
+	dirty bool
+}
+
+// NewVideoRAM creates a new, empty screen of the specified size
+func NewVideoRAM(cols int, rows int) *VideoRAM {
+	return &VideoRAM{
+		cursor:   NewCursorPos(cols, rows, []rune{95, 0}),
+		textCols: cols,
+		textRows: rows,
+		cells:    make([]rune, cols*rows),
+		dirty:    true,
+	}
+}
+
+// GetTextSize returns the number of columns and rows
+func (v *VideoRAM) GetTextSize() (cols, rows int) {
+	return v.textCols, v.textRows
+}
+
+// IsBusy returns if the screen is busy
+func (v *VideoRAM) IsBusy() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.busy
+}
+
+// MarkDirty forces the next Draw to report a change
+func (v *VideoRAM) MarkDirty() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.dirty = true
+}
+
+// ProcessRune processes an ASCII character
+func (v *VideoRAM) ProcessRune(r rune) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.dirty = true
+	v.busy = true
+	if v.escapeMode {
+		v.matchesEscapeCode(r)
+	} else {
+		switch {
+		case r == ascii.Backspace: // backspace
+			v.cursor.Backspace()
+			v.cells[v.indexOf(v.cursor.X, v.cursor.Y)] = 0
+		case r == ascii.Escape:
+			v.escapeMode = true
+			v.escapeSequence = ""
+		case r == ascii.Enter: // enter
+			v.cursor.NewLine()
+		case r < 32 || r > 126:
+			// Nothing
+		default:
+			v.cells[v.indexOf(v.cursor.X, v.cursor.Y)] = r
+			v.cursor.NextLocation()
+		}
+	}
+
+	if v.cursor.Scroll {
+		v.scrollUp()
+		v.cursor.ClearScroll()
+	}
+	v.busy = false
+}
+
+// Reset resets the screen to startup state
+func (v *VideoRAM) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.dirty = true
+	v.escapeMode = false
+	v.cursor.X = 0
+	v.cursor.Y = 0
+	v.cursor.ClearScroll()
+	v.clear()
+}
+
+// Draw calls the function with the screen contents, one rune
+// per cell row by row with 0 for an empty cell, and the cursor.
+// The function must not hold on to either after it returns.
+// Draw returns false without calling it if nothing has changed
+// since the last time, unless force is set.
+func (v *VideoRAM) Draw(force bool, f func(cells []rune, cursor *CursorPos)) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if !v.dirty && !force {
+		return false
+	}
+
+	f(v.cells, v.cursor)
+	v.dirty = false
+	return true
+}
+
+func (v *VideoRAM) matchesEscapeCode(r rune) {
+	v.escapeSequence += string(r)
+	for _, c := range escapeCodes {
+		if c.Matcher.MatchString(v.escapeSequence) {
+			c.Action(v, v.escapeSequence)
+			v.escapeMode = false
+		}
+	}
+
+	if v.escapeMode && len(v.escapeSequence) > escapeMaxLength {
+		v.escapeMode = false
+	}
+}
+
+func (v *VideoRAM) indexOf(x, y int) int {
+	return (y * v.textCols) + x
+}
+
+func (v *VideoRAM) clear() {
+	for i := range v.cells {
+		v.cells[i] = 0
+	}
+}
+
+func (v *VideoRAM) scrollUp() {
+	copy(v.cells, v.cells[v.textCols:])
+	for i := len(v.cells) - v.textCols; i < len(v.cells); i++ {
+		v.cells[i] = 0
+	}
+}
+
+func (v *VideoRAM) scrollDown() {
+	copy(v.cells[v.textCols:], v.cells)
+	for i := 0; i < v.textCols; i++ {
+		v.cells[i] = 0
+	}
+}
+
+func findFirstNumber(s string, def int) int {
+	numStr := numMatcher.FindString(s)
+	result, err := strconv.Atoi(numStr)
+	if err != nil {
+		result = def
+	}
+	return result
+}
+
+func findTwoNumbers(s string, def int) (int, int) {
+	strs := strings.Split(s, ";")
+	if len(strs) != 2 {
+		return def, def
+	}
+	n1 := findFirstNumber(strs[0], def)
+	n2 := findFirstNumber(strs[1], def)
+	return n1, n2
+}